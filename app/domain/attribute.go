@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"context"
+	"regexp"
+)
+
+// AttributeCodePattern restricts attribute codes to lowercase snake_case.
+var AttributeCodePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+type AttributeType string
+
+const (
+	AttributeTypeString AttributeType = "string"
+	AttributeTypeNumber AttributeType = "number"
+	AttributeTypeEnum   AttributeType = "enum"
+	AttributeTypeBool   AttributeType = "bool"
+)
+
+// AttributeDefinition describes one typed attribute products of a category
+// may (or, when Required, must) carry.
+type AttributeDefinition struct {
+	ID       int64         `json:"id"`
+	Category string        `json:"category"`
+	Code     string        `json:"code"`
+	Name     string        `json:"name"`
+	Type     AttributeType `json:"type"`
+	Required bool          `json:"required"`
+	Unit     string        `json:"unit,omitempty"`
+	Options  []string      `json:"options,omitempty"`
+}
+
+type AttributeDefinitionRequest struct {
+	Code     string        `json:"code" validate:"required,max=64"`
+	Name     string        `json:"name" validate:"required"`
+	Type     AttributeType `json:"type" validate:"required,oneof=string number enum bool"`
+	Required bool          `json:"required"`
+	Unit     string        `json:"unit"`
+	Options  []string      `json:"options" validate:"required_if=Type enum,dive,required"`
+}
+
+type SetAttributeSchemaRequest struct {
+	Attributes []AttributeDefinitionRequest `json:"attributes" validate:"dive"`
+}
+
+type AttributeFilterOp string
+
+const (
+	AttributeFilterEq  AttributeFilterOp = "="
+	AttributeFilterNe  AttributeFilterOp = "!="
+	AttributeFilterGt  AttributeFilterOp = ">"
+	AttributeFilterGte AttributeFilterOp = ">="
+	AttributeFilterLt  AttributeFilterOp = "<"
+	AttributeFilterLte AttributeFilterOp = "<="
+)
+
+// AttributeFilter is one attr.<code><op><value> condition of a product query.
+// Range operators compare numerically, equality compares the text value.
+type AttributeFilter struct {
+	Code  string
+	Op    AttributeFilterOp
+	Value string
+}
+
+type AttributeRepository interface {
+	GetByCategory(ctx context.Context, category string) ([]*AttributeDefinition, error)
+	ReplaceForCategory(ctx context.Context, category string, attributes []*AttributeDefinition) error
+}
+
+type AttributeUsecase interface {
+	GetSchema(ctx context.Context, category string) ([]*AttributeDefinition, error)
+	SetSchema(ctx context.Context, category string, req *SetAttributeSchemaRequest) ([]*AttributeDefinition, error)
+}
@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type Category struct {
+	ID        int64       `json:"id"`
+	ParentID  *int64      `json:"parent_id"`
+	Name      string      `json:"name"`
+	Slug      string      `json:"slug"`
+	Children  []*Category `json:"children,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+type CreateCategoryRequest struct {
+	Name     string `json:"name" validate:"required,max=100"`
+	Slug     string `json:"slug" validate:"omitempty,max=100"`
+	ParentID *int64 `json:"parent_id" validate:"omitempty,gt=0"`
+}
+
+type CategoryRepository interface {
+	GetAll(ctx context.Context) ([]*Category, error)
+	GetByID(ctx context.Context, id int64) (*Category, error)
+	GetBySlug(ctx context.Context, slug string) (*Category, error)
+	Create(ctx context.Context, category *Category) error
+}
+
+type CategoryUsecase interface {
+	GetTree(ctx context.Context) ([]*Category, error)
+	Create(ctx context.Context, req *CreateCategoryRequest) (*Category, error)
+}
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// DeadLetter is a stock message the consumer gave up on, kept on the dead
+// letter subject until it is replayed.
+type DeadLetter struct {
+	Sequence         uint64    `json:"sequence"`
+	Subject          string    `json:"subject"`
+	OriginalSequence uint64    `json:"original_sequence"`
+	Reason           string    `json:"reason"`
+	Deliveries       uint64    `json:"deliveries"`
+	RequestID        string    `json:"request_id,omitempty"`
+	Data             string    `json:"data"`
+	FailedAt         time.Time `json:"failed_at"`
+}
+
+type DeadLetterRepository interface {
+	Publish(ctx context.Context, letter *DeadLetter) error
+	List(ctx context.Context, afterSequence uint64, limit int) ([]*DeadLetter, error)
+	Get(ctx context.Context, sequence uint64) (*DeadLetter, error)
+	Delete(ctx context.Context, sequence uint64) error
+	// Republish sends the letter back to its original subject.
+	Republish(ctx context.Context, letter *DeadLetter) error
+}
@@ -3,10 +3,15 @@ package domain
 import "errors"
 
 var (
-	ErrNotFound       = errors.New("not found")
-	ErrBadRequest     = errors.New("bad request")
-	ErrInvalidRequest = errors.New("invalid request")
-	ErrValidation     = errors.New("validation error")
-	ErrUnauthorized   = errors.New("unauthorized")
-	ErrInternal       = errors.New("internal server error")
+	ErrNotFound             = errors.New("not found")
+	ErrBadRequest           = errors.New("bad request")
+	ErrInvalidRequest       = errors.New("invalid request")
+	ErrValidation           = errors.New("validation error")
+	ErrUnauthorized         = errors.New("unauthorized")
+	ErrForbidden            = errors.New("forbidden")
+	ErrConflict             = errors.New("conflict")
+	ErrPreconditionFailed   = errors.New("precondition failed")
+	ErrPreconditionRequired = errors.New("precondition required")
+	ErrStockUnavailable     = errors.New("stock unavailable")
+	ErrInternal             = errors.New("internal server error")
 )
@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// ProductEventSchemaVersion is bumped on every incompatible change to
+// ProductEvent so consumers can tell payload shapes apart.
+const ProductEventSchemaVersion = 1
+
+const (
+	ProductEventCreated      = "product.created"
+	ProductEventUpdated      = "product.updated"
+	ProductEventPriceChanged = "product.price_changed"
+	ProductEventActivated    = "product.activated"
+	ProductEventDeactivated  = "product.deactivated"
+)
+
+// ProductEvent is published whenever the catalog changes. ID is derived from
+// the product version, so redelivering the same change is deduplicated by
+// JetStream.
+type ProductEvent struct {
+	ID            string                `json:"id"`
+	Type          string                `json:"type"`
+	SchemaVersion int                   `json:"schema_version"`
+	OccurredAt    time.Time             `json:"occurred_at"`
+	ProductID     int64                 `json:"product_id"`
+	ShopID        int64                 `json:"shop_id"`
+	Version       int64                 `json:"version"`
+	PreviousPrice *int64                `json:"previous_price,omitempty"`
+	Product       *ProductEventSnapshot `json:"product"`
+}
+
+type ProductEventSnapshot struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Price       int64          `json:"price"`
+	CategoryID  int64          `json:"category_id"`
+	Category    string         `json:"category"`
+	ImageURL    string         `json:"image_url"`
+	Attributes  map[string]any `json:"attributes"`
+	Active      bool           `json:"active"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// ProductEventMessage is the outbox payload of a product event. The request
+// ID of the change travels along to end up in the message headers.
+type ProductEventMessage struct {
+	RequestID string       `json:"request_id,omitempty"`
+	Event     ProductEvent `json:"event"`
+}
+
+type ProductEventPublisher interface {
+	Publish(ctx context.Context, event ProductEvent, requestID string) error
+}
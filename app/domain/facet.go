@@ -0,0 +1,38 @@
+package domain
+
+const (
+	FacetCategory = "category"
+	FacetPrice    = "price"
+	FacetShop     = "shop"
+)
+
+// PriceFacetBounds are the price bucket boundaries of the price facet. A
+// product falls into the bucket [bounds[i-1], bounds[i]).
+var PriceFacetBounds = []int64{50000, 100000, 250000, 500000, 1000000, 5000000}
+
+type ProductFacets struct {
+	Category []*CategoryFacet `json:"category,omitempty"`
+	Price    []*PriceFacet    `json:"price,omitempty"`
+	Shop     []*ShopFacet     `json:"shop,omitempty"`
+}
+
+// CategoryFacet counts the products of a category and all its descendants,
+// like the category filter matches them, so a parent counts its children's
+// products too. ParentID lets clients render the counts as a tree.
+type CategoryFacet struct {
+	CategoryID int64  `json:"category_id"`
+	ParentID   *int64 `json:"parent_id,omitempty"`
+	Category   string `json:"category"`
+	Count      int64  `json:"count"`
+}
+
+type PriceFacet struct {
+	Min   *int64 `json:"min"`
+	Max   *int64 `json:"max"`
+	Count int64  `json:"count"`
+}
+
+type ShopFacet struct {
+	ShopID int64 `json:"shop_id"`
+	Count  int64 `json:"count"`
+}
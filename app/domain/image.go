@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type ProductImage struct {
+	ID        int64     `json:"id"`
+	ProductID int64     `json:"product_id"`
+	URL       string    `json:"url"`
+	AltText   string    `json:"alt_text"`
+	Position  int       `json:"position"`
+	Primary   bool      `json:"primary"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type AddImageRequest struct {
+	URL     string `json:"url" validate:"required,url,max=2048"`
+	AltText string `json:"alt_text" validate:"max=255"`
+	Primary bool   `json:"primary"`
+}
+
+type ReorderImagesRequest struct {
+	ImageIDs []int64 `json:"image_ids" validate:"required,min=1,unique,dive,gt=0"`
+}
+
+// ImageRepository keeps products.image_url in sync with the primary image of
+// the gallery.
+type ImageRepository interface {
+	GetByProductID(ctx context.Context, productID int64) ([]*ProductImage, error)
+	GetByProductIDs(ctx context.Context, productIDs []int64) (map[int64][]*ProductImage, error)
+	// Create joins the transaction carried by ctx, if any.
+	Create(ctx context.Context, image *ProductImage) error
+	SetPrimary(ctx context.Context, productID, id int64) error
+	SetPrimaryByURL(ctx context.Context, productID int64, url string) error
+	Reorder(ctx context.Context, productID int64, imageIDs []int64) error
+	Delete(ctx context.Context, productID, id int64) error
+}
+
+type ImageUsecase interface {
+	GetByProductID(ctx context.Context, productID int64) ([]*ProductImage, error)
+	Add(ctx context.Context, productID int64, req *AddImageRequest) (*ProductImage, error)
+	SetPrimary(ctx context.Context, productID, id int64) ([]*ProductImage, error)
+	Reorder(ctx context.Context, productID int64, req *ReorderImagesRequest) ([]*ProductImage, error)
+	Delete(ctx context.Context, productID, id int64) ([]*ProductImage, error)
+}
@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Outbox topics name the side effect a message stands for.
+const (
+	OutboxTopicInitStock    = "warehouse.init_stock"
+	OutboxTopicProductEvent = "nats.product_event"
+)
+
+// OutboxMessage is a side effect recorded in the same transaction as the
+// change that caused it and delivered afterwards by the outbox relay, at
+// least once.
+type OutboxMessage struct {
+	ID        int64           `json:"id"`
+	Topic     string          `json:"topic"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"last_error,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type OutboxRepository interface {
+	// Enqueue records a message in the transaction carried by ctx and fails
+	// outside of one.
+	Enqueue(ctx context.Context, topic string, payload any) error
+	// Claim leases up to limit due messages for lease, so concurrent relays
+	// do not deliver the same message at the same time.
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]*OutboxMessage, error)
+	MarkDelivered(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, retryAt time.Time, cause error) error
+	MarkDead(ctx context.Context, id int64, cause error) error
+	// DeleteFinished deletes up to limit messages delivered or given up on
+	// before the given time and returns how many it deleted.
+	DeleteFinished(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+type OutboxRelay interface {
+	Run(ctx context.Context)
+}
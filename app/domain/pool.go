@@ -0,0 +1,17 @@
+package domain
+
+// DBPoolStats is a snapshot of a database connection pool.
+type DBPoolStats struct {
+	MaxConns                int32 `json:"max_conns"`
+	TotalConns              int32 `json:"total_conns"`
+	AcquiredConns           int32 `json:"acquired_conns"`
+	IdleConns               int32 `json:"idle_conns"`
+	ConstructingConns       int32 `json:"constructing_conns"`
+	AcquireCount            int64 `json:"acquire_count"`
+	AcquireDurationMs       int64 `json:"acquire_duration_ms"`
+	EmptyAcquireCount       int64 `json:"empty_acquire_count"`
+	CanceledAcquireCount    int64 `json:"canceled_acquire_count"`
+	NewConnsCount           int64 `json:"new_conns_count"`
+	MaxLifetimeDestroyCount int64 `json:"max_lifetime_destroy_count"`
+	MaxIdleDestroyCount     int64 `json:"max_idle_destroy_count"`
+}
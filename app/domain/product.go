@@ -2,54 +2,104 @@ package domain
 
 import (
 	"context"
-	"database/sql"
 	"time"
 )
 
 type Product struct {
-	ID          int64     `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Price       int64     `json:"price"`
-	Category    string    `json:"category"`
-	ImageURL    string    `json:"image_url"`
-	ShopID      int64     `json:"shop_id"`
-	Active      bool      `json:"active"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int64            `json:"id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Price       int64            `json:"price"`
+	CategoryID  int64            `json:"category_id"`
+	Category    string           `json:"category"`
+	ImageURL    string           `json:"image_url"`
+	ShopID      int64            `json:"shop_id"`
+	Attributes  map[string]any   `json:"attributes"`
+	Images      []*ProductImage  `json:"images,omitempty"`
+	Highlight   *SearchHighlight `json:"highlight,omitempty"`
+	Active      bool             `json:"active"`
+	Version     int64            `json:"version"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
 }
 
+// ProductQuery filters on Category (slug) or CategoryID also match products of
+// every descendant category.
 type ProductQuery struct {
-	ShopID    int64  `query:"shop_id"`
-	Category  string `query:"category"`
-	MinPrice  int64  `query:"min_price"`
-	MaxPrice  int64  `query:"max_price"`
-	Keyword   string `query:"keyword"`
-	SortBy    string `query:"sort_by"`
-	SortOrder string `query:"sort_order"`
-	Page      int    `query:"page"`
-	Limit     int    `query:"limit"`
+	ShopID       int64  `query:"shop_id"`
+	CategoryID   int64  `query:"category_id"`
+	Category     string `query:"category"`
+	MinPrice     int64  `query:"min_price"`
+	MaxPrice     int64  `query:"max_price"`
+	Keyword      string `query:"keyword"`
+	SortBy       string `query:"sort_by"`
+	SortOrder    string `query:"sort_order"`
+	Cursor       string `query:"cursor"`
+	Limit        int    `query:"limit"`
+	IncludeTotal bool   `query:"include_total"`
+
+	After      *ProductCursor    `query:"-"`
+	Attributes []AttributeFilter `query:"-"`
+	Facets     []string          `query:"-"`
+}
+
+// ProductCursor is the keyset position behind an opaque cursor token: the
+// sort key of the last product of a page plus its ID as tie breaker. A cursor
+// is only valid for the sort it was issued for.
+type ProductCursor struct {
+	SortBy    string     `json:"s"`
+	SortOrder string     `json:"o"`
+	CreatedAt *time.Time `json:"c,omitempty"`
+	Price     *int64     `json:"p,omitempty"`
+	Rank      *float64   `json:"r,omitempty"`
+	ID        int64      `json:"id"`
+}
+
+type ProductListResponse struct {
+	Products   []*ProductResponse `json:"products"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+	HasMore    bool               `json:"has_more"`
+	Total      *int64             `json:"total,omitempty"`
+	Facets     *ProductFacets     `json:"facets,omitempty"`
+}
+
+// SearchHighlight is set on products found by keyword search. Name and
+// Description carry the matched terms wrapped in <mark> tags.
+type SearchHighlight struct {
+	Rank        float64 `json:"rank"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
 }
 
 type ProductResponse struct {
-	ID          int64     `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Price       int64     `json:"price"`
-	Category    string    `json:"category"`
-	ImageURL    string    `json:"image_url"`
-	ShopID      int64     `json:"shop_id"`
-	Stock       int       `json:"stock"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int64              `json:"id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Price       int64              `json:"price"`
+	CategoryID  int64              `json:"category_id"`
+	Category    string             `json:"category"`
+	ImageURL    string             `json:"image_url"`
+	ShopID      int64              `json:"shop_id"`
+	Attributes  map[string]any     `json:"attributes"`
+	Images      []*ProductImage    `json:"images"`
+	Stock       int                `json:"stock"`
+	StockStatus string             `json:"stock_status"`
+	Variants    []*VariantResponse `json:"variants,omitempty"`
+	Highlight   *SearchHighlight   `json:"highlight,omitempty"`
+	Version     int64              `json:"version"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
 }
 
 type CreateProductRequest struct {
 	Name        string `json:"name" validate:"required"`
 	Description string `json:"description" validate:"required"`
-	Price       int64  `json:"price" validate:"required"`
-	Category    string `json:"category" validate:"required"`
+	Price       int64  `json:"price" validate:"required,gt=0"`
+	CategoryID  int64  `json:"category_id" validate:"required,gt=0"`
 	ImageURL    string `json:"image_url" validate:"required"`
+
+	Attributes map[string]any         `json:"attributes"`
+	Variants   []CreateVariantRequest `json:"variants" validate:"omitempty,dive"`
 }
 
 type CreateProductResponse struct {
@@ -59,11 +109,25 @@ type CreateProductResponse struct {
 type UpdateProductRequest struct {
 	Name        string `json:"name" validate:"required"`
 	Description string `json:"description" validate:"required"`
-	Price       int64  `json:"price" validate:"required"`
-	Category    string `json:"category" validate:"required"`
+	Price       int64  `json:"price" validate:"required,gt=0"`
+	CategoryID  int64  `json:"category_id" validate:"required,gt=0"`
+	ImageURL    string `json:"image_url" validate:"required"`
+	Active      *bool  `json:"active" validate:"required"`
+
+	Attributes map[string]any `json:"attributes"`
+}
+
+// PatchProductRequest is the document a JSON Merge Patch is applied to. Fields
+// missing from the patch keep their current values.
+type PatchProductRequest struct {
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description" validate:"required"`
+	Price       int64  `json:"price" validate:"required,gt=0"`
+	CategoryID  int64  `json:"category_id" validate:"required,gt=0"`
 	ImageURL    string `json:"image_url" validate:"required"`
-	ShopID      int64  `json:"shop_id" validate:"required"`
-	Active      bool   `json:"active" validate:"required"`
+	Active      bool   `json:"active"`
+
+	Attributes map[string]any `json:"attributes"`
 }
 
 type SetActiveStatusRequest struct {
@@ -73,23 +137,43 @@ type SetActiveStatusRequest struct {
 type ProductReadRepository interface {
 	GetByID(ctx context.Context, id int64) (*Product, error)
 	GetListByQuery(ctx context.Context, query ProductQuery) ([]*Product, error)
+	CountByQuery(ctx context.Context, query ProductQuery) (int64, error)
+	GetFacets(ctx context.Context, query ProductQuery, facets []string) (*ProductFacets, error)
+}
+
+// CachedProductReadRepository is a ProductReadRepository that keeps product
+// details in a cache. Writers must Invalidate a product once their change is
+// committed.
+type CachedProductReadRepository interface {
+	ProductReadRepository
+	ProductCacheInvalidator
+}
+
+type ProductCacheInvalidator interface {
+	Invalidate(ctx context.Context, id int64) error
 }
 
 type ProductReadUsecase interface {
 	GetByID(ctx context.Context, id int64) (*ProductResponse, error)
-	GetListByQuery(ctx context.Context, query ProductQuery) ([]*Product, error)
+	GetListByQuery(ctx context.Context, query ProductQuery) (*ProductListResponse, error)
 }
 
 type ProductWriteRepository interface {
+	GetByID(ctx context.Context, id int64) (*Product, error)
 	Create(ctx context.Context, product *Product) error
 	Update(ctx context.Context, product *Product) error
-	SetActiveStatus(ctx context.Context, id int64, active bool) error
-
-	WithTransaction(ctx context.Context, fn func(context.Context, *sql.Tx) error) error
+	SetActiveStatus(ctx context.Context, id int64, version int64, active bool) (int64, error)
+	// BumpVersion bumps the version of a product whose dependent data, e.g.
+	// its variants, changed. The product row stays locked until the
+	// transaction carried by ctx ends.
+	BumpVersion(ctx context.Context, id int64) (int64, error)
+	// Lock locks the product row until the transaction carried by ctx ends.
+	Lock(ctx context.Context, id int64) error
 }
 
 type ProductWriteUsecase interface {
 	Create(ctx context.Context, shopID int64, product *CreateProductRequest) (*CreateProductResponse, error)
-	Update(ctx context.Context, id int64, product *UpdateProductRequest) (*Product, error)
-	SetActiveStatus(ctx context.Context, id int64, active bool) error
+	Update(ctx context.Context, id int64, version int64, product *UpdateProductRequest) (*Product, error)
+	Patch(ctx context.Context, id int64, version int64, patch []byte) (*Product, error)
+	SetActiveStatus(ctx context.Context, id int64, version int64, active bool) (int64, error)
 }
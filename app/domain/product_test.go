@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+)
+
+func TestProductRequestsRejectNonPositivePrice(t *testing.T) {
+	active := true
+	requests := map[string]func(price int64) any{
+		"create": func(price int64) any {
+			return CreateProductRequest{Name: "Lamp", Description: "Desk lamp", Price: price, CategoryID: 1, ImageURL: "https://cdn.example.com/lamp.jpg"}
+		},
+		"update": func(price int64) any {
+			return UpdateProductRequest{Name: "Lamp", Description: "Desk lamp", Price: price, CategoryID: 1, ImageURL: "https://cdn.example.com/lamp.jpg", Active: &active}
+		},
+		"patch": func(price int64) any {
+			return PatchProductRequest{Name: "Lamp", Description: "Desk lamp", Price: price, CategoryID: 1, ImageURL: "https://cdn.example.com/lamp.jpg"}
+		},
+	}
+
+	validate := validator.New()
+	for name, request := range requests {
+		t.Run(name, func(t *testing.T) {
+			if err := validate.Struct(request(150000)); err != nil {
+				t.Fatalf("price 150000: %v", err)
+			}
+			for _, price := range []int64{0, -1} {
+				if err := validate.Struct(request(price)); err == nil {
+					t.Fatalf("price %d: want error", price)
+				}
+			}
+		})
+	}
+}
@@ -2,25 +2,85 @@ package domain
 
 import (
 	"context"
+	"time"
 )
 
+// StockStatus tells clients whether Stock can be trusted. It is unknown when
+// the warehouse service could not be reached and nothing was cached.
+const (
+	StockStatusInStock    = "in_stock"
+	StockStatusOutOfStock = "out_of_stock"
+	StockStatusUnknown    = "unknown"
+)
+
+// StockMessage carries the available stock of a product or variant. Version
+// must grow with every change of that stock; when the warehouse does not send
+// one, the event timestamp is used instead. Messages without either are
+// applied unconditionally.
 type StockMessage struct {
-	ProductID int64 `json:"product_id"`
-	Available int   `json:"available"`
+	ProductID  int64     `json:"product_id"`
+	VariantID  int64     `json:"variant_id,omitempty"`
+	Available  int       `json:"available"`
+	Version    int64     `json:"version,omitempty"`
+	OccurredAt time.Time `json:"occurred_at,omitzero"`
+}
+
+// Kinds of stock ordering keys. Versions and event timestamps are on
+// different scales, so a key is only compared with keys of the same kind.
+const (
+	StockOrderVersion    = "version"
+	StockOrderOccurredAt = "occurred_at"
+)
+
+// OrderingKey is what stock updates are compared by and its kind. The key is
+// 0 when the message carries no ordering information.
+func (m StockMessage) OrderingKey() (string, int64) {
+	if m.Version > 0 {
+		return StockOrderVersion, m.Version
+	}
+	if !m.OccurredAt.IsZero() {
+		return StockOrderOccurredAt, m.OccurredAt.UnixMicro()
+	}
+	return "", 0
 }
 
 type InitStockRequest struct {
 	ShopID    int64 `json:"shop_id"`
 	ProductID int64 `json:"product_id"`
+	VariantID int64 `json:"variant_id,omitempty"`
 }
 
 type StockRepository interface {
 	GetStock(ctx context.Context, productID int64) (int, error)
 	FetchStockFromService(ctx context.Context, productID int64) (int, error)
+	// CacheStock overwrites the cached stock, FillStock only sets it when
+	// nothing is cached, so a warehouse read never replaces a newer update.
 	CacheStock(ctx context.Context, productID int64, stock int) error
-	InitStockToWarehouse(ctx context.Context, req InitStockRequest) error
+	FillStock(ctx context.Context, productID int64, stock int) error
+	AcquireStockLock(ctx context.Context, productID int64, ttl time.Duration) (string, error)
+	ReleaseStockLock(ctx context.Context, productID int64, token string) error
+	GetStocks(ctx context.Context, productIDs []int64) (map[int64]int, error)
+	FetchStocksFromService(ctx context.Context, productIDs []int64) (map[int64]int, error)
+	FillStocks(ctx context.Context, stocks map[int64]int) error
+	GetVariantStock(ctx context.Context, variantID int64) (int, error)
+	GetVariantStocks(ctx context.Context, variantIDs []int64) (map[int64]int, error)
+	FetchVariantStockFromService(ctx context.Context, variantID int64) (int, error)
+	FetchVariantStocksFromService(ctx context.Context, variantIDs []int64) (map[int64]int, error)
+	CacheVariantStock(ctx context.Context, variantID int64, stock int) error
+	FillVariantStock(ctx context.Context, variantID int64, stock int) error
+	FillVariantStocks(ctx context.Context, stocks map[int64]int) error
+	// CompareAndSetStock caches stock only if version is newer than the last
+	// applied one of the same kind and reports whether it did.
+	CompareAndSetStock(ctx context.Context, productID int64, stock int, kind string, version int64) (bool, error)
+	CompareAndSetVariantStock(ctx context.Context, variantID int64, stock int, kind string, version int64) (bool, error)
+	// InitStockToWarehouse creates the stock in the warehouse. Repeating a
+	// call with the same idempotencyKey must not create it twice.
+	InitStockToWarehouse(ctx context.Context, req InitStockRequest, idempotencyKey string) error
 }
 
 type StockUsecase interface {
 	UpdateStock(ctx context.Context, msg StockMessage) error
+	DeadLetter(ctx context.Context, letter *DeadLetter) error
+	ListDeadLetters(ctx context.Context, afterSequence uint64, limit int) ([]*DeadLetter, error)
+	ReplayDeadLetter(ctx context.Context, sequence uint64) error
 }
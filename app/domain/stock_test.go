@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStockMessageOrderingKey(t *testing.T) {
+	occurredAt := time.Date(2025, 3, 1, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		msg      StockMessage
+		wantKind string
+		wantKey  int64
+	}{
+		{name: "version", msg: StockMessage{Version: 7}, wantKind: StockOrderVersion, wantKey: 7},
+		{name: "version wins over timestamp", msg: StockMessage{Version: 7, OccurredAt: occurredAt}, wantKind: StockOrderVersion, wantKey: 7},
+		{name: "timestamp", msg: StockMessage{OccurredAt: occurredAt}, wantKind: StockOrderOccurredAt, wantKey: occurredAt.UnixMicro()},
+		{name: "none", msg: StockMessage{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, key := tt.msg.OrderingKey()
+			if kind != tt.wantKind || key != tt.wantKey {
+				t.Fatalf("OrderingKey() = %q, %d, want %q, %d", kind, key, tt.wantKind, tt.wantKey)
+			}
+		})
+	}
+}
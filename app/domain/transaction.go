@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+)
+
+// TxOptions configures a transaction started by a TransactionManager.
+type TxOptions struct {
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+}
+
+type TxOption func(*TxOptions)
+
+// WithIsolation runs the transaction at the given isolation level instead of
+// the database default.
+func WithIsolation(level sql.IsolationLevel) TxOption {
+	return func(opts *TxOptions) {
+		opts.Isolation = level
+	}
+}
+
+// ReadOnly starts a read only transaction.
+func ReadOnly() TxOption {
+	return func(opts *TxOptions) {
+		opts.ReadOnly = true
+	}
+}
+
+// TransactionManager runs fn in a transaction carried by the context it
+// passes on, so every repository call made with that context joins it. A
+// nested call runs in a savepoint of the surrounding transaction: its failure
+// only rolls back its own work.
+type TransactionManager interface {
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error, opts ...TxOption) error
+}
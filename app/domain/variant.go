@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// ProductVariant is a purchasable variant of a product. A product without
+// variants keeps its stock at product level. Once it has variants the stock
+// is kept per variant and the product stock is their sum; when the last
+// variant is deleted the product falls back to its product-level stock.
+type ProductVariant struct {
+	ID        int64             `json:"id"`
+	ProductID int64             `json:"product_id"`
+	SKU       string            `json:"sku"`
+	Options   map[string]string `json:"options"`
+	Price     *int64            `json:"price,omitempty"`
+	ImageURL  string            `json:"image_url"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+type VariantResponse struct {
+	ID          int64             `json:"id"`
+	ProductID   int64             `json:"product_id"`
+	SKU         string            `json:"sku"`
+	Options     map[string]string `json:"options"`
+	Price       int64             `json:"price"`
+	ImageURL    string            `json:"image_url"`
+	Stock       int               `json:"stock"`
+	StockStatus string            `json:"stock_status"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+type CreateVariantRequest struct {
+	SKU      string            `json:"sku" validate:"required,max=64"`
+	Options  map[string]string `json:"options" validate:"required,min=1,dive,keys,required,endkeys,required"`
+	Price    *int64            `json:"price" validate:"omitempty,gt=0"`
+	ImageURL string            `json:"image_url" validate:"omitempty,url"`
+}
+
+type UpdateVariantRequest struct {
+	SKU      string            `json:"sku" validate:"required,max=64"`
+	Options  map[string]string `json:"options" validate:"required,min=1,dive,keys,required,endkeys,required"`
+	Price    *int64            `json:"price" validate:"omitempty,gt=0"`
+	ImageURL string            `json:"image_url" validate:"omitempty,url"`
+}
+
+type VariantRepository interface {
+	GetByID(ctx context.Context, productID, id int64) (*ProductVariant, error)
+	GetByProductID(ctx context.Context, productID int64) ([]*ProductVariant, error)
+	GetByProductIDs(ctx context.Context, productIDs []int64) (map[int64][]*ProductVariant, error)
+	Create(ctx context.Context, variant *ProductVariant) error
+	Update(ctx context.Context, variant *ProductVariant) error
+	Delete(ctx context.Context, productID, id int64) error
+}
+
+type VariantUsecase interface {
+	GetByProductID(ctx context.Context, productID int64) ([]*VariantResponse, error)
+	Create(ctx context.Context, productID int64, req *CreateVariantRequest) (*ProductVariant, error)
+	Update(ctx context.Context, productID, id int64, req *UpdateVariantRequest) (*ProductVariant, error)
+	Delete(ctx context.Context, productID, id int64) error
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"log/slog"
+	"product-service/app/domain"
+	"product-service/app/handler/response"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type attributeHandler struct {
+	attributeUsecase domain.AttributeUsecase
+	validator        *validator.Validate
+}
+
+func NewAttributeHandler(attributeUsecase domain.AttributeUsecase, validator *validator.Validate) *attributeHandler {
+	return &attributeHandler{attributeUsecase, validator}
+}
+
+func (h *attributeHandler) GetSchema(c *fiber.Ctx) error {
+	category := c.Params("category")
+	if category == "" {
+		slog.ErrorContext(c.Context(), "[attributeHandler] GetSchema", "params", "category is empty")
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	attributes, err := h.attributeUsecase.GetSchema(c.Context(), category)
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[attributeHandler] GetSchema", "usecase", err)
+		status, response := response.FromError(err)
+		return c.Status(status).JSON(response)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.Success(attributes))
+}
+
+func (h *attributeHandler) SetSchema(c *fiber.Ctx) error {
+	category := c.Params("category")
+	if category == "" {
+		slog.ErrorContext(c.Context(), "[attributeHandler] SetSchema", "params", "category is empty")
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	var req domain.SetAttributeSchemaRequest
+	if err := c.BodyParser(&req); err != nil {
+		slog.ErrorContext(c.Context(), "[attributeHandler] SetSchema", "body", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		slog.ErrorContext(c.Context(), "[attributeHandler] SetSchema", "validation", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	attributes, err := h.attributeUsecase.SetSchema(c.Context(), category, &req)
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[attributeHandler] SetSchema", "usecase", err)
+		status, response := response.FromError(err)
+		return c.Status(status).JSON(response)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.Success(attributes))
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"log/slog"
+	"product-service/app/domain"
+	"product-service/app/handler/response"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type categoryHandler struct {
+	categoryUsecase domain.CategoryUsecase
+	validator       *validator.Validate
+}
+
+func NewCategoryHandler(categoryUsecase domain.CategoryUsecase, validator *validator.Validate) *categoryHandler {
+	return &categoryHandler{categoryUsecase, validator}
+}
+
+func (h *categoryHandler) GetTree(c *fiber.Ctx) error {
+	categories, err := h.categoryUsecase.GetTree(c.Context())
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[categoryHandler] GetTree", "usecase", err)
+		status, response := response.FromError(err)
+		return c.Status(status).JSON(response)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.Success(categories))
+}
+
+func (h *categoryHandler) Create(c *fiber.Ctx) error {
+	var req domain.CreateCategoryRequest
+	if err := c.BodyParser(&req); err != nil {
+		slog.ErrorContext(c.Context(), "[categoryHandler] Create", "body", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		slog.ErrorContext(c.Context(), "[categoryHandler] Create", "validation", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	res, err := h.categoryUsecase.Create(c.Context(), &req)
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[categoryHandler] Create", "usecase", err)
+		status, response := response.FromError(err)
+		return c.Status(status).JSON(response)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(response.Success(res))
+}
@@ -2,26 +2,221 @@ package handler
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"product-service/config"
+	"product-service/pkg/metrics"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/nats-io/nats.go/jetstream"
 )
 
-func SetupConsumer(ctx context.Context, stream jetstream.Stream, stockConsumerHandler *StockConsumerHandler) {
+const (
+	stockAvailableSubject        = "stock.available"
+	defaultConsumerDurable       = "processor"
+	defaultConsumerFilterSubject = stockAvailableSubject
+	defaultConsumerAckWait       = 30 * time.Second
+	defaultConsumerMaxAckPending = 1000
+	defaultConsumerWorkers       = 4
+	consumerInfoTimeout          = 2 * time.Second
+)
+
+// Consumer processes messages of a durable JetStream consumer on a fixed
+// pool of workers. Each message goes to the worker its partition key maps to,
+// so messages with the same key are handled one at a time and in the order
+// they were delivered.
+type Consumer struct {
+	consumeCtx jetstream.ConsumeContext
+	routes     map[string]consumerRoute
+	queues     []chan jetstream.Msg
+	workers    sync.WaitGroup
+}
+
+// consumerRoute is how messages of one subject are handled. partitionKey
+// picks the worker; messages it cannot key may return 0.
+type consumerRoute struct {
+	handle       jetstream.MessageHandler
+	partitionKey func(msg jetstream.Msg) uint64
+}
+
+func newConsumer(routes map[string]consumerRoute, workers int) *Consumer {
+	c := &Consumer{
+		routes: routes,
+		queues: make([]chan jetstream.Msg, workers),
+	}
+	for i := range c.queues {
+		queue := make(chan jetstream.Msg)
+		c.queues[i] = queue
+		c.workers.Add(1)
+		go func() {
+			defer c.workers.Done()
+			for msg := range queue {
+				c.routes[msg.Subject()].handle(msg)
+			}
+		}()
+	}
+	return c
+}
+
+// SetupConsumer creates or updates the durable consumer described by cfg and
+// starts consuming it. Every filter subject must name a subject a handler is
+// registered for, wildcards are not supported. Messages on other subjects are
+// terminated so they are not redelivered.
+//
+// Stock updates are keyed by product, so updates of one product, including
+// its variants, are applied in delivery order. This matters for messages
+// without a version, which the stock usecase applies unconditionally. A
+// message that is naked and redelivered can still arrive after newer ones.
+func SetupConsumer(ctx context.Context, stream jetstream.Stream, cfg config.NatsConfig, stockConsumerHandler *StockConsumerHandler) (*Consumer, error) {
+	durable := cfg.ConsumerDurable
+	if durable == "" {
+		durable = defaultConsumerDurable
+	}
+	filterSubjects := splitSubjects(cfg.ConsumerFilterSubjects)
+	if len(filterSubjects) == 0 {
+		filterSubjects = []string{defaultConsumerFilterSubject}
+	}
+	ackWait := time.Duration(cfg.ConsumerAckWaitSeconds) * time.Second
+	if ackWait <= 0 {
+		ackWait = defaultConsumerAckWait
+	}
+	maxAckPending := cfg.ConsumerMaxAckPending
+	if maxAckPending <= 0 {
+		maxAckPending = defaultConsumerMaxAckPending
+	}
+	workers := cfg.ConsumerWorkers
+	if workers <= 0 {
+		workers = defaultConsumerWorkers
+	}
+
+	routes, err := consumerRoutes(filterSubjects, map[string]consumerRoute{
+		stockAvailableSubject: {
+			handle:       stockConsumerHandler.UpdateStock,
+			partitionKey: stockConsumerHandler.PartitionKey,
+		},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "[SetupConsumer] consumerRoutes", "error", err)
+		return nil, err
+	}
 
 	cons, err := stream.CreateOrUpdateConsumer(ctx, jetstream.ConsumerConfig{
-		Durable: "processor",
+		Durable:        durable,
+		FilterSubjects: filterSubjects,
+		AckPolicy:      jetstream.AckExplicitPolicy,
+		AckWait:        ackWait,
+		MaxAckPending:  maxAckPending,
+		MaxDeliver:     stockConsumerHandler.maxDeliver,
 	})
 	if err != nil {
 		slog.ErrorContext(ctx, "[SetupConsumer] CreateOrUpdateConsumer", "error", err)
-		return
+		return nil, err
 	}
 
-	cons.Consume(func(msg jetstream.Msg) {
-		if msg.Subject() != "stock.available" {
-			return
+	c := newConsumer(routes, workers)
+
+	c.consumeCtx, err = cons.Consume(c.dispatch,
+		jetstream.PullMaxMessages(workers*2),
+		jetstream.ConsumeErrHandler(func(_ jetstream.ConsumeContext, err error) {
+			slog.WarnContext(ctx, "[SetupConsumer] Consume", "error", err)
+		}),
+	)
+	if err != nil {
+		slog.ErrorContext(ctx, "[SetupConsumer] Consume", "error", err)
+		c.closeQueues()
+		return nil, err
+	}
+
+	metrics.RegisterConsumer(durable, func() (uint64, uint64, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), consumerInfoTimeout)
+		defer cancel()
+
+		info, err := cons.Info(ctx)
+		if err != nil {
+			return 0, 0, err
 		}
-		stockConsumerHandler.UpdateStock(msg)
+		return info.NumPending, uint64(info.NumAckPending), nil
 	})
-	slog.InfoContext(ctx, "[SetupConsumer] Consumer setup successfully", "subject", "stock.available")
+
+	slog.InfoContext(ctx, "[SetupConsumer] Consumer setup successfully",
+		"durable", durable, "subjects", filterSubjects, "ack_wait", ackWait, "max_ack_pending", maxAckPending, "workers", workers)
+	return c, nil
+}
+
+// consumerRoutes picks the handlers of the filter subjects and fails for a
+// subject nothing handles, which would otherwise be pulled only to be
+// terminated.
+func consumerRoutes(filterSubjects []string, handlers map[string]consumerRoute) (map[string]consumerRoute, error) {
+	routes := make(map[string]consumerRoute, len(filterSubjects))
+	for _, subject := range filterSubjects {
+		route, ok := handlers[subject]
+		if !ok {
+			return nil, fmt.Errorf("no handler for consumer filter subject %q", subject)
+		}
+		routes[subject] = route
+	}
+	return routes, nil
+}
+
+// dispatch blocks until the worker for the message's partition key is free,
+// which keeps the client from pulling more than it can process.
+func (c *Consumer) dispatch(msg jetstream.Msg) {
+	route, ok := c.routes[msg.Subject()]
+	if !ok {
+		slog.Warn("[Consumer] no handler for subject, terminating message", "subject", msg.Subject())
+		if err := msg.TermWithReason("no handler for subject"); err != nil {
+			slog.Error("[Consumer] Term failed", "subject", msg.Subject(), "error", err)
+			return
+		}
+		metrics.ConsumerOutcome(msg.Subject(), "term")
+		return
+	}
+
+	key := route.partitionKey(msg)
+	c.queues[key%uint64(len(c.queues))] <- msg
+}
+
+// closeQueues lets the workers exit once they have handled the messages
+// already dispatched. dispatch must not be called afterwards.
+func (c *Consumer) closeQueues() {
+	for _, queue := range c.queues {
+		close(queue)
+	}
+}
+
+// Stop stops pulling new messages and waits for buffered and in-flight ones
+// to finish, or for ctx to expire. Unacknowledged messages are redelivered
+// after AckWait.
+func (c *Consumer) Stop(ctx context.Context) error {
+	c.consumeCtx.Drain()
+
+	done := make(chan struct{})
+	go func() {
+		<-c.consumeCtx.Closed()
+		c.closeQueues()
+		c.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		slog.InfoContext(ctx, "[Consumer] stopped")
+		return nil
+	case <-ctx.Done():
+		c.consumeCtx.Stop()
+		slog.WarnContext(ctx, "[Consumer] stop deadline exceeded, abandoning in-flight messages")
+		return ctx.Err()
+	}
+}
+
+func splitSubjects(subjects string) []string {
+	var out []string
+	for _, subject := range strings.Split(subjects, ",") {
+		if subject = strings.TrimSpace(subject); subject != "" {
+			out = append(out, subject)
+		}
+	}
+	return out
 }
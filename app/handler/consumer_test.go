@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+type keyedMsg struct {
+	jetstream.Msg
+	key, seq int
+}
+
+func (m *keyedMsg) Subject() string { return "stock.available" }
+
+func TestConsumerHandlesSameKeyInOrder(t *testing.T) {
+	var mu sync.Mutex
+	handled := map[int][]int{}
+	c := newConsumer(map[string]consumerRoute{
+		"stock.available": {
+			handle: func(msg jetstream.Msg) {
+				m := msg.(*keyedMsg)
+				// Later messages finish faster, so any concurrency on a key
+				// would reorder them.
+				time.Sleep(time.Duration(10-m.seq) * time.Millisecond)
+				mu.Lock()
+				handled[m.key] = append(handled[m.key], m.seq)
+				mu.Unlock()
+			},
+			partitionKey: func(msg jetstream.Msg) uint64 { return uint64(msg.(*keyedMsg).key) },
+		},
+	}, 4)
+
+	for seq := range 10 {
+		for key := 1; key <= 3; key++ {
+			c.dispatch(&keyedMsg{key: key, seq: seq})
+		}
+	}
+	c.closeQueues()
+	c.workers.Wait()
+
+	for key := 1; key <= 3; key++ {
+		got := fmt.Sprint(handled[key])
+		if want := "[0 1 2 3 4 5 6 7 8 9]"; got != want {
+			t.Fatalf("key %d handled in order %s, want %s", key, got, want)
+		}
+	}
+}
+
+func TestStockPartitionKey(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want uint64
+	}{
+		{name: "product", data: `{"product_id":42,"available":3}`, want: 42},
+		{name: "variant keyed by its product", data: `{"product_id":42,"variant_id":7,"available":3}`, want: 42},
+		{name: "malformed", data: `{`, want: 0},
+		{name: "missing product", data: `{"available":3}`, want: 0},
+	}
+
+	h := NewStockConsumerHandler(nil, 0)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := h.PartitionKey(&dataMsg{data: tt.data}); got != tt.want {
+				t.Fatalf("PartitionKey = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+type dataMsg struct {
+	jetstream.Msg
+	data string
+}
+
+func (m *dataMsg) Data() []byte { return []byte(m.data) }
+
+func TestConsumerRoutes(t *testing.T) {
+	handlers := map[string]consumerRoute{stockAvailableSubject: {}}
+
+	tests := []struct {
+		name     string
+		subjects []string
+		wantErr  bool
+	}{
+		{name: "handled subject", subjects: []string{stockAvailableSubject}},
+		{name: "subject without handler", subjects: []string{stockAvailableSubject, "stock.reserved"}, wantErr: true},
+		{name: "wildcard", subjects: []string{"stock.>"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routes, err := consumerRoutes(tt.subjects, handlers)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && len(routes) != len(tt.subjects) {
+				t.Fatalf("routes = %v, want one per subject", routes)
+			}
+		})
+	}
+}
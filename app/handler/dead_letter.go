@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"log/slog"
+	"product-service/app/domain"
+	"product-service/app/handler/response"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultDeadLetterPage = 50
+	maxDeadLetterPage     = 100
+)
+
+type deadLetterHandler struct {
+	stockUsecase domain.StockUsecase
+}
+
+func NewDeadLetterHandler(stockUsecase domain.StockUsecase) *deadLetterHandler {
+	return &deadLetterHandler{stockUsecase}
+}
+
+// List pages through dead letters in stream order, starting after the
+// sequence given in the after query parameter.
+func (h *deadLetterHandler) List(c *fiber.Ctx) error {
+	after, err := strconv.ParseUint(c.Query("after", "0"), 10, 64)
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[deadLetterHandler] List", "after", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	limit := c.QueryInt("limit", defaultDeadLetterPage)
+	if limit < 1 {
+		limit = defaultDeadLetterPage
+	}
+	limit = min(limit, maxDeadLetterPage)
+
+	letters, err := h.stockUsecase.ListDeadLetters(c.Context(), after, limit)
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[deadLetterHandler] List", "usecase", err)
+		status, response := response.FromError(err)
+		return c.Status(status).JSON(response)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.Success(letters))
+}
+
+func (h *deadLetterHandler) Replay(c *fiber.Ctx) error {
+	sequence, err := strconv.ParseUint(c.Params("sequence"), 10, 64)
+	if err != nil || sequence == 0 {
+		slog.ErrorContext(c.Context(), "[deadLetterHandler] Replay", "params", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	if err := h.stockUsecase.ReplayDeadLetter(c.Context(), sequence); err != nil {
+		slog.ErrorContext(c.Context(), "[deadLetterHandler] Replay", "usecase", err)
+		status, response := response.FromError(err)
+		return c.Status(status).JSON(response)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.Success(map[string]uint64{"sequence": sequence}))
+}
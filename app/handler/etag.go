@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"product-service/app/domain"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func setVersionETag(c *fiber.Ctx, version int64) {
+	c.Set(fiber.HeaderETag, strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+// parseIfMatch reads the product version from the If-Match header. Only a
+// single strong entity tag is accepted, as produced by setVersionETag. A weak
+// tag never matches under the strong comparison If-Match requires.
+func parseIfMatch(c *fiber.Ctx) (int64, error) {
+	ifMatch := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if ifMatch == "" {
+		return 0, domain.ErrPreconditionRequired
+	}
+
+	if strings.HasPrefix(ifMatch, "W/") {
+		return 0, domain.ErrPreconditionFailed
+	}
+
+	unquoted, err := strconv.Unquote(ifMatch)
+	if err != nil {
+		return 0, domain.ErrBadRequest
+	}
+
+	version, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, domain.ErrBadRequest
+	}
+
+	return version, nil
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"context"
+	"net/http/httptest"
+	"product-service/app/domain"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+// versionedProductUsecase accepts writes only at currentVersion, like the
+// version compare in the write repository.
+type versionedProductUsecase struct {
+	domain.ProductWriteUsecase
+	currentVersion int64
+}
+
+func (u *versionedProductUsecase) SetActiveStatus(ctx context.Context, id int64, version int64, active bool) (int64, error) {
+	if version != u.currentVersion {
+		return 0, domain.ErrPreconditionFailed
+	}
+	return version + 1, nil
+}
+
+func TestParseIfMatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		ifMatch     string
+		wantVersion int64
+		wantErr     error
+	}{
+		{name: "quoted", ifMatch: `"3"`, wantVersion: 3},
+		{name: "quoted with spaces", ifMatch: `  "42" `, wantVersion: 42},
+		{name: "missing", ifMatch: "", wantErr: domain.ErrPreconditionRequired},
+		{name: "weak", ifMatch: `W/"3"`, wantErr: domain.ErrPreconditionFailed},
+		{name: "unquoted", ifMatch: "3", wantErr: domain.ErrBadRequest},
+		{name: "not a number", ifMatch: `"abc"`, wantErr: domain.ErrBadRequest},
+		{name: "zero", ifMatch: `"0"`, wantErr: domain.ErrBadRequest},
+		{name: "list", ifMatch: `"3", "4"`, wantErr: domain.ErrBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			var gotVersion int64
+			var gotErr error
+			app.Get("/", func(c *fiber.Ctx) error {
+				gotVersion, gotErr = parseIfMatch(c)
+				return nil
+			})
+
+			req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+			if tt.ifMatch != "" {
+				req.Header.Set(fiber.HeaderIfMatch, tt.ifMatch)
+			}
+			if _, err := app.Test(req); err != nil {
+				t.Fatalf("app.Test: %v", err)
+			}
+
+			if gotErr != tt.wantErr {
+				t.Fatalf("err = %v, want %v", gotErr, tt.wantErr)
+			}
+			if gotVersion != tt.wantVersion {
+				t.Fatalf("version = %d, want %d", gotVersion, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestSetActiveStatusIfMatchStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		ifMatch    string
+		wantStatus int
+		wantETag   string
+	}{
+		{name: "matching", ifMatch: `"3"`, wantStatus: fiber.StatusOK, wantETag: `"4"`},
+		{name: "missing", ifMatch: "", wantStatus: fiber.StatusPreconditionRequired},
+		{name: "mismatched", ifMatch: `"2"`, wantStatus: fiber.StatusPreconditionFailed},
+		{name: "weak", ifMatch: `W/"3"`, wantStatus: fiber.StatusPreconditionFailed},
+		{name: "malformed", ifMatch: "3", wantStatus: fiber.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewProductWriteHandler(&versionedProductUsecase{currentVersion: 3}, validator.New())
+			app := fiber.New()
+			app.Patch("/products/:id/status", h.SetActiveStatus)
+
+			req := httptest.NewRequest(fiber.MethodPatch, "/products/1/status", strings.NewReader(`{"active":false}`))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			if tt.ifMatch != "" {
+				req.Header.Set(fiber.HeaderIfMatch, tt.ifMatch)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test: %v", err)
+			}
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := resp.Header.Get(fiber.HeaderETag); got != tt.wantETag {
+				t.Fatalf("ETag = %q, want %q", got, tt.wantETag)
+			}
+		})
+	}
+}
+
+func TestUpdateProductRequestAcceptsInactive(t *testing.T) {
+	active := false
+	req := domain.UpdateProductRequest{
+		Name:        "Lamp",
+		Description: "Desk lamp",
+		Price:       150000,
+		CategoryID:  1,
+		ImageURL:    "https://cdn.example.com/lamp.jpg",
+		Active:      &active,
+	}
+	if err := validator.New().Struct(req); err != nil {
+		t.Fatalf("validate active=false: %v", err)
+	}
+
+	req.Active = nil
+	if err := validator.New().Struct(req); err == nil {
+		t.Fatal("validate without active: want error")
+	}
+}
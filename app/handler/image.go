@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"log/slog"
+	"product-service/app/domain"
+	"product-service/app/handler/response"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type imageHandler struct {
+	imageUsecase domain.ImageUsecase
+	validator    *validator.Validate
+}
+
+func NewImageHandler(imageUsecase domain.ImageUsecase, validator *validator.Validate) *imageHandler {
+	return &imageHandler{imageUsecase, validator}
+}
+
+func (h *imageHandler) GetByProductID(c *fiber.Ctx) error {
+	productID, err := paramID(c, "id")
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[imageHandler] GetByProductID", "params", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	images, err := h.imageUsecase.GetByProductID(c.Context(), productID)
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[imageHandler] GetByProductID", "usecase", err)
+		status, response := response.FromError(err)
+		return c.Status(status).JSON(response)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.Success(images))
+}
+
+func (h *imageHandler) Add(c *fiber.Ctx) error {
+	productID, err := paramID(c, "id")
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[imageHandler] Add", "params", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	var req domain.AddImageRequest
+	if err := c.BodyParser(&req); err != nil {
+		slog.ErrorContext(c.Context(), "[imageHandler] Add", "body", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		slog.ErrorContext(c.Context(), "[imageHandler] Add", "validation", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	res, err := h.imageUsecase.Add(c.Context(), productID, &req)
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[imageHandler] Add", "usecase", err)
+		status, response := response.FromError(err)
+		return c.Status(status).JSON(response)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(response.Success(res))
+}
+
+func (h *imageHandler) SetPrimary(c *fiber.Ctx) error {
+	productID, err := paramID(c, "id")
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[imageHandler] SetPrimary", "params", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	imageID, err := paramID(c, "imageId")
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[imageHandler] SetPrimary", "params", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	res, err := h.imageUsecase.SetPrimary(c.Context(), productID, imageID)
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[imageHandler] SetPrimary", "usecase", err)
+		status, response := response.FromError(err)
+		return c.Status(status).JSON(response)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.Success(res))
+}
+
+func (h *imageHandler) Reorder(c *fiber.Ctx) error {
+	productID, err := paramID(c, "id")
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[imageHandler] Reorder", "params", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	var req domain.ReorderImagesRequest
+	if err := c.BodyParser(&req); err != nil {
+		slog.ErrorContext(c.Context(), "[imageHandler] Reorder", "body", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		slog.ErrorContext(c.Context(), "[imageHandler] Reorder", "validation", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	res, err := h.imageUsecase.Reorder(c.Context(), productID, &req)
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[imageHandler] Reorder", "usecase", err)
+		status, response := response.FromError(err)
+		return c.Status(status).JSON(response)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.Success(res))
+}
+
+func (h *imageHandler) Delete(c *fiber.Ctx) error {
+	productID, err := paramID(c, "id")
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[imageHandler] Delete", "params", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	imageID, err := paramID(c, "imageId")
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[imageHandler] Delete", "params", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	res, err := h.imageUsecase.Delete(c.Context(), productID, imageID)
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[imageHandler] Delete", "usecase", err)
+		status, response := response.FromError(err)
+		return c.Status(status).JSON(response)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.Success(res))
+}
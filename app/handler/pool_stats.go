@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"product-service/app/domain"
+	"product-service/app/handler/response"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type poolStatsHandler struct {
+	stats func() map[string]domain.DBPoolStats
+}
+
+// NewPoolStatsHandler serves the snapshots returned by stats, keyed by pool
+// name.
+func NewPoolStatsHandler(stats func() map[string]domain.DBPoolStats) *poolStatsHandler {
+	return &poolStatsHandler{stats}
+}
+
+func (h *poolStatsHandler) Get(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(response.Success(h.stats()))
+}
@@ -1,10 +1,13 @@
 package handler
 
 import (
+	"fmt"
 	"log/slog"
 	"product-service/app/domain"
 	"product-service/app/handler/response"
+	"product-service/pkg"
 	"strconv"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
@@ -39,6 +42,7 @@ func (h *productReadHandler) GetByID(c *fiber.Ctx) error {
 		return c.Status(status).JSON(response)
 	}
 
+	setVersionETag(c, product.Version)
 	return c.Status(fiber.StatusOK).JSON(response.Success(product))
 }
 
@@ -49,28 +53,159 @@ func (h *productReadHandler) GetListByQuery(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
 	}
 
-	if query.Page < 1 {
-		query.Page = 1
-	}
 	if query.Limit < 1 {
 		query.Limit = 10
 	}
 	if query.Limit > 20 {
 		query.Limit = 20
 	}
-	if query.SortBy == "" || (query.SortBy != "created_at" && query.SortBy != "price") {
+	if query.SortBy == "" && query.Keyword != "" {
+		query.SortBy = "relevance"
+	}
+	if query.SortBy == "" || (query.SortBy != "created_at" && query.SortBy != "price" && query.SortBy != "relevance") ||
+		(query.SortBy == "relevance" && query.Keyword == "") {
 		query.SortBy = "created_at"
 	}
 	if query.SortOrder == "" || query.SortOrder != "asc" {
 		query.SortOrder = "desc"
 	}
 
-	products, err := h.productUsecase.GetListByQuery(c.Context(), query)
+	if query.Cursor != "" {
+		after, err := parseProductCursor(query)
+		if err != nil {
+			slog.ErrorContext(c.Context(), "[productReadHandler] GetListByQuery", "cursor", err)
+			return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+		}
+		query.After = after
+	}
+
+	attributes, err := parseAttributeFilters(c)
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[productReadHandler] GetListByQuery", "attributes", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+	query.Attributes = attributes
+
+	facets, err := parseFacets(c.Query("facets"))
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[productReadHandler] GetListByQuery", "facets", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+	query.Facets = facets
+
+	res, err := h.productUsecase.GetListByQuery(c.Context(), query)
 	if err != nil {
 		slog.ErrorContext(c.Context(), "[productReadHandler] GetListByQuery", "usecase", err)
 		status, response := response.FromError(err)
 		return c.Status(status).JSON(response)
 	}
 
-	return c.Status(fiber.StatusOK).JSON(response.Success(products))
+	return c.Status(fiber.StatusOK).JSON(response.Success(res))
+}
+
+// parseProductCursor decodes the cursor token of query and checks it was
+// issued for the same sort.
+func parseProductCursor(query domain.ProductQuery) (*domain.ProductCursor, error) {
+	var cursor domain.ProductCursor
+	if err := pkg.DecodeCursor(query.Cursor, &cursor); err != nil {
+		return nil, err
+	}
+	if cursor.SortBy != query.SortBy || cursor.SortOrder != query.SortOrder {
+		return nil, fmt.Errorf("cursor was issued for sort %s %s", cursor.SortBy, cursor.SortOrder)
+	}
+
+	var hasKey bool
+	switch cursor.SortBy {
+	case "price":
+		hasKey = cursor.Price != nil
+	case "relevance":
+		hasKey = cursor.Rank != nil
+	default:
+		hasKey = cursor.CreatedAt != nil
+	}
+	if !hasKey || cursor.ID <= 0 {
+		return nil, fmt.Errorf("cursor is missing its position")
+	}
+
+	return &cursor, nil
+}
+
+// parseFacets reads the comma separated facets parameter, e.g.
+// facets=category,price. Duplicates are dropped.
+func parseFacets(raw string) ([]string, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var facets []string
+	seen := map[string]bool{}
+	for _, facet := range strings.Split(raw, ",") {
+		facet = strings.TrimSpace(facet)
+		switch facet {
+		case domain.FacetCategory, domain.FacetPrice, domain.FacetShop:
+		default:
+			return nil, fmt.Errorf("unknown facet %q", facet)
+		}
+		if !seen[facet] {
+			seen[facet] = true
+			facets = append(facets, facet)
+		}
+	}
+
+	return facets, nil
+}
+
+const (
+	attributeFilterPrefix = "attr."
+	maxAttributeFilters   = 10
+)
+
+// parseAttributeFilters reads attr.<code><op><value> query parameters. Since
+// the URL query splits on the first "=", attr.ram_gb>=8 arrives as key
+// "attr.ram_gb>" with value "8", while attr.ram_gb>8 arrives as a bare key.
+func parseAttributeFilters(c *fiber.Ctx) ([]domain.AttributeFilter, error) {
+	var filters []domain.AttributeFilter
+	var parseErr error
+	c.Context().QueryArgs().VisitAll(func(k, v []byte) {
+		key := string(k)
+		if parseErr != nil || !strings.HasPrefix(key, attributeFilterPrefix) {
+			return
+		}
+		expr, value := strings.TrimPrefix(key, attributeFilterPrefix), string(v)
+
+		var filter domain.AttributeFilter
+		if i := strings.IndexAny(expr, "<>"); i >= 0 && i < len(expr)-1 && value == "" {
+			filter = domain.AttributeFilter{Code: expr[:i], Op: domain.AttributeFilterOp(expr[i : i+1]), Value: expr[i+1:]}
+		} else {
+			filter = domain.AttributeFilter{Code: expr, Op: domain.AttributeFilterEq, Value: value}
+			switch {
+			case strings.HasSuffix(expr, ">"):
+				filter.Code, filter.Op = strings.TrimSuffix(expr, ">"), domain.AttributeFilterGte
+			case strings.HasSuffix(expr, "<"):
+				filter.Code, filter.Op = strings.TrimSuffix(expr, "<"), domain.AttributeFilterLte
+			case strings.HasSuffix(expr, "!"):
+				filter.Code, filter.Op = strings.TrimSuffix(expr, "!"), domain.AttributeFilterNe
+			}
+		}
+
+		if !domain.AttributeCodePattern.MatchString(filter.Code) || filter.Value == "" {
+			parseErr = fmt.Errorf("invalid attribute filter %q", key)
+			return
+		}
+		if filter.Op != domain.AttributeFilterEq && filter.Op != domain.AttributeFilterNe {
+			if _, err := strconv.ParseFloat(filter.Value, 64); err != nil {
+				parseErr = fmt.Errorf("attribute filter %q needs a numeric value", key)
+				return
+			}
+		}
+		filters = append(filters, filter)
+	})
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	if len(filters) > maxAttributeFilters {
+		return nil, fmt.Errorf("too many attribute filters: %d", len(filters))
+	}
+
+	return filters, nil
 }
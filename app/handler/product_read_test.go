@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"product-service/app/domain"
+	"product-service/pkg"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestParseAttributeFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    []domain.AttributeFilter
+		wantErr bool
+	}{
+		{name: "none", query: "category=laptops"},
+		{
+			name:  "equal",
+			query: "attr.color=red",
+			want:  []domain.AttributeFilter{{Code: "color", Op: domain.AttributeFilterEq, Value: "red"}},
+		},
+		{
+			name:  "not equal",
+			query: "attr.color%21=red",
+			want:  []domain.AttributeFilter{{Code: "color", Op: domain.AttributeFilterNe, Value: "red"}},
+		},
+		{
+			name:  "greater or equal",
+			query: "attr.ram_gb%3E=8",
+			want:  []domain.AttributeFilter{{Code: "ram_gb", Op: domain.AttributeFilterGte, Value: "8"}},
+		},
+		{
+			name:  "less or equal",
+			query: "attr.ram_gb%3C=16",
+			want:  []domain.AttributeFilter{{Code: "ram_gb", Op: domain.AttributeFilterLte, Value: "16"}},
+		},
+		{
+			name:  "greater",
+			query: "attr.ram_gb%3E8",
+			want:  []domain.AttributeFilter{{Code: "ram_gb", Op: domain.AttributeFilterGt, Value: "8"}},
+		},
+		{
+			name:  "less",
+			query: "attr.weight_kg%3C2.5",
+			want:  []domain.AttributeFilter{{Code: "weight_kg", Op: domain.AttributeFilterLt, Value: "2.5"}},
+		},
+		{
+			name:  "several with other parameters",
+			query: "attr.color=red&limit=10&attr.ram_gb%3E=8",
+			want: []domain.AttributeFilter{
+				{Code: "color", Op: domain.AttributeFilterEq, Value: "red"},
+				{Code: "ram_gb", Op: domain.AttributeFilterGte, Value: "8"},
+			},
+		},
+		{name: "empty value", query: "attr.color=", wantErr: true},
+		{name: "invalid code", query: "attr.Color-Name=red", wantErr: true},
+		{name: "empty code", query: "attr.=red", wantErr: true},
+		{name: "non numeric range", query: "attr.ram_gb%3E=lots", wantErr: true},
+		{name: "non numeric bare range", query: "attr.ram_gb%3Clots", wantErr: true},
+		{
+			name:    "too many",
+			query:   strings.Repeat("attr.color=red&", maxAttributeFilters) + "attr.size=m",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			var got []domain.AttributeFilter
+			var gotErr error
+			app.Get("/", func(c *fiber.Ctx) error {
+				got, gotErr = parseAttributeFilters(c)
+				return nil
+			})
+
+			if _, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/?"+tt.query, nil)); err != nil {
+				t.Fatalf("app.Test: %v", err)
+			}
+
+			if tt.wantErr {
+				if gotErr == nil {
+					t.Fatalf("want error, got filters %+v", got)
+				}
+				return
+			}
+			if gotErr != nil {
+				t.Fatalf("unexpected error: %v", gotErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("filters = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseProductCursor(t *testing.T) {
+	createdAt := time.Date(2025, 3, 1, 10, 30, 0, 0, time.UTC)
+	price := int64(150000)
+	rank := 0.25
+
+	encode := func(t *testing.T, cursor any) string {
+		t.Helper()
+		token, err := pkg.EncodeCursor(cursor)
+		if err != nil {
+			t.Fatalf("EncodeCursor: %v", err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name    string
+		query   domain.ProductQuery
+		cursor  any
+		token   string
+		wantErr bool
+	}{
+		{
+			name:   "created_at",
+			query:  domain.ProductQuery{SortBy: "created_at", SortOrder: "asc"},
+			cursor: domain.ProductCursor{SortBy: "created_at", SortOrder: "asc", CreatedAt: &createdAt, ID: 7},
+		},
+		{
+			name:   "price",
+			query:  domain.ProductQuery{SortBy: "price", SortOrder: "desc"},
+			cursor: domain.ProductCursor{SortBy: "price", SortOrder: "desc", Price: &price, ID: 7},
+		},
+		{
+			name:   "relevance",
+			query:  domain.ProductQuery{SortBy: "relevance", SortOrder: "desc"},
+			cursor: domain.ProductCursor{SortBy: "relevance", SortOrder: "desc", Rank: &rank, ID: 7},
+		},
+		{
+			name:    "foreign sort key",
+			query:   domain.ProductQuery{SortBy: "created_at", SortOrder: "asc"},
+			cursor:  domain.ProductCursor{SortBy: "price", SortOrder: "asc", Price: &price, ID: 7},
+			wantErr: true,
+		},
+		{
+			name:    "foreign sort order",
+			query:   domain.ProductQuery{SortBy: "price", SortOrder: "asc"},
+			cursor:  domain.ProductCursor{SortBy: "price", SortOrder: "desc", Price: &price, ID: 7},
+			wantErr: true,
+		},
+		{
+			name:    "missing sort key",
+			query:   domain.ProductQuery{SortBy: "price", SortOrder: "asc"},
+			cursor:  domain.ProductCursor{SortBy: "price", SortOrder: "asc", CreatedAt: &createdAt, ID: 7},
+			wantErr: true,
+		},
+		{
+			name:    "missing id",
+			query:   domain.ProductQuery{SortBy: "created_at", SortOrder: "asc"},
+			cursor:  domain.ProductCursor{SortBy: "created_at", SortOrder: "asc", CreatedAt: &createdAt},
+			wantErr: true,
+		},
+		{
+			name:    "tampered token",
+			query:   domain.ProductQuery{SortBy: "created_at", SortOrder: "asc"},
+			token:   "eyJzIjoiY3JlYXRlZF9hdCIsIm8iOiJhc2MiLCJpZCI6N30!",
+			wantErr: true,
+		},
+		{
+			name:    "foreign token",
+			query:   domain.ProductQuery{SortBy: "created_at", SortOrder: "asc"},
+			cursor:  map[string]any{"page": 2},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := tt.query
+			query.Cursor = tt.token
+			if tt.cursor != nil {
+				query.Cursor = encode(t, tt.cursor)
+			}
+
+			got, err := parseProductCursor(query)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("want error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			want := tt.cursor.(domain.ProductCursor)
+			if got.ID != want.ID || got.SortBy != want.SortBy || got.SortOrder != want.SortOrder {
+				t.Fatalf("cursor = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
@@ -6,11 +6,14 @@ import (
 	"product-service/app/handler/response"
 	"product-service/pkg/ctxutil"
 	"strconv"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 )
 
+const mergePatchContentType = "application/merge-patch+json"
+
 type productWriteHandler struct {
 	productUsecase domain.ProductWriteUsecase
 	validator      *validator.Validate
@@ -61,6 +64,13 @@ func (h *productWriteHandler) Update(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
 	}
 
+	version, err := parseIfMatch(c)
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[productWriteHandler] Update", "ifMatch", err)
+		status, response := response.FromError(err)
+		return c.Status(status).JSON(response)
+	}
+
 	var product domain.UpdateProductRequest
 	if err := c.BodyParser(&product); err != nil {
 		slog.ErrorContext(c.Context(), "[productWriteHandler] Update", "body", err)
@@ -72,13 +82,51 @@ func (h *productWriteHandler) Update(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
 	}
 
-	res, err := h.productUsecase.Update(c.Context(), id, &product)
+	res, err := h.productUsecase.Update(c.Context(), id, version, &product)
 	if err != nil {
 		slog.ErrorContext(c.Context(), "[productWriteHandler] Update", "usecase", err)
 		status, response := response.FromError(err)
 		return c.Status(status).JSON(response)
 	}
 
+	setVersionETag(c, res.Version)
+	return c.Status(fiber.StatusOK).JSON(response.Success(res))
+}
+
+func (h *productWriteHandler) Patch(c *fiber.Ctx) error {
+	idstr := c.Params("id")
+	if idstr == "" {
+		slog.ErrorContext(c.Context(), "[productWriteHandler] Patch", "params", "product ID is empty")
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	id, err := strconv.ParseInt(idstr, 10, 64)
+	if err != nil || id <= 0 {
+		slog.ErrorContext(c.Context(), "[productWriteHandler] Patch", "params:"+idstr, err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	version, err := parseIfMatch(c)
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[productWriteHandler] Patch", "ifMatch", err)
+		status, response := response.FromError(err)
+		return c.Status(status).JSON(response)
+	}
+
+	contentType := strings.ToLower(c.Get(fiber.HeaderContentType))
+	if !strings.HasPrefix(contentType, mergePatchContentType) && !strings.HasPrefix(contentType, fiber.MIMEApplicationJSON) {
+		slog.ErrorContext(c.Context(), "[productWriteHandler] Patch", "contentType", contentType)
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	res, err := h.productUsecase.Patch(c.Context(), id, version, c.Body())
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[productWriteHandler] Patch", "usecase", err)
+		status, response := response.FromError(err)
+		return c.Status(status).JSON(response)
+	}
+
+	setVersionETag(c, res.Version)
 	return c.Status(fiber.StatusOK).JSON(response.Success(res))
 }
 
@@ -95,18 +143,26 @@ func (h *productWriteHandler) SetActiveStatus(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
 	}
 
+	version, err := parseIfMatch(c)
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[productWriteHandler] SetActiveStatus", "ifMatch", err)
+		status, response := response.FromError(err)
+		return c.Status(status).JSON(response)
+	}
+
 	var req domain.SetActiveStatusRequest
 	if err := c.BodyParser(&req); err != nil {
 		slog.ErrorContext(c.Context(), "[productWriteHandler] SetActiveStatus", "body", err)
 		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
 	}
 
-	err = h.productUsecase.SetActiveStatus(c.Context(), id, req.Active)
+	newVersion, err := h.productUsecase.SetActiveStatus(c.Context(), id, version, req.Active)
 	if err != nil {
 		slog.ErrorContext(c.Context(), "[productWriteHandler] SetActiveStatus", "usecase", err)
 		status, response := response.FromError(err)
 		return c.Status(status).JSON(response)
 	}
 
+	setVersionETag(c, newVersion)
 	return c.Status(fiber.StatusOK).JSON(response.Success[any](nil))
 }
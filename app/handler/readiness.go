@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultReadinessTimeout  = time.Second
+	defaultReadinessCacheTTL = 2 * time.Second
+)
+
+// ReadinessCheck reports whether a dependency can serve requests.
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+type readinessHandler struct {
+	checks   []ReadinessCheck
+	timeout  time.Duration
+	cacheTTL time.Duration
+
+	shuttingDown atomic.Bool
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	ready     bool
+}
+
+// NewReadinessHandler runs every check concurrently, each bounded by timeout,
+// and reuses the result for cacheTTL so frequent probes do not hammer the
+// dependencies.
+func NewReadinessHandler(timeout, cacheTTL time.Duration, checks ...ReadinessCheck) *readinessHandler {
+	if timeout <= 0 {
+		timeout = defaultReadinessTimeout
+	}
+	if cacheTTL <= 0 {
+		cacheTTL = defaultReadinessCacheTTL
+	}
+	return &readinessHandler{
+		checks:   checks,
+		timeout:  timeout,
+		cacheTTL: cacheTTL,
+	}
+}
+
+// MarkNotReady makes every following probe fail so load balancers stop
+// routing traffic while the service shuts down.
+func (h *readinessHandler) MarkNotReady() {
+	h.shuttingDown.Store(true)
+}
+
+// Probe is the readiness probe of the healthcheck middleware.
+func (h *readinessHandler) Probe(c *fiber.Ctx) bool {
+	if h.shuttingDown.Load() {
+		return false
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if time.Since(h.checkedAt) < h.cacheTTL {
+		return h.ready
+	}
+
+	h.ready = h.check(c.Context())
+	h.checkedAt = time.Now()
+	return h.ready
+}
+
+func (h *readinessHandler) check(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	var (
+		wg    sync.WaitGroup
+		ready atomic.Bool
+	)
+	ready.Store(true)
+	for _, check := range h.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := check.Check(ctx); err != nil {
+				slog.WarnContext(ctx, "[readinessHandler] dependency not ready", "dependency", check.Name, "error", err)
+				ready.Store(false)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return ready.Load()
+}
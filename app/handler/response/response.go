@@ -35,10 +35,20 @@ func FromError(err error) (int, *Response[any]) {
 		return fiber.StatusBadRequest, Error(err)
 	case errors.Is(err, domain.ErrUnauthorized):
 		return fiber.StatusUnauthorized, Error(err)
+	case errors.Is(err, domain.ErrForbidden):
+		return fiber.StatusForbidden, Error(err)
+	case errors.Is(err, domain.ErrConflict):
+		return fiber.StatusConflict, Error(err)
+	case errors.Is(err, domain.ErrPreconditionFailed):
+		return fiber.StatusPreconditionFailed, Error(err)
+	case errors.Is(err, domain.ErrPreconditionRequired):
+		return fiber.StatusPreconditionRequired, Error(err)
 	case errors.Is(err, domain.ErrNotFound):
 		return fiber.StatusNotFound, Error(err)
 	case errors.Is(err, domain.ErrBadRequest):
 		return fiber.StatusBadRequest, Error(err)
+	case errors.Is(err, domain.ErrStockUnavailable):
+		return fiber.StatusServiceUnavailable, Error(domain.ErrStockUnavailable)
 	default:
 		return fiber.StatusInternalServerError, Error(domain.ErrInternal)
 	}
@@ -3,20 +3,50 @@ package handler
 import (
 	"product-service/app/middleware"
 	"product-service/config"
+	"product-service/pkg/metrics"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
 )
 
-func SetupRouter(app *fiber.App, readProductHandler *productReadHandler, writeProductHandler *productWriteHandler, cfg *config.Config) {
+func SetupRouter(app *fiber.App, readProductHandler *productReadHandler, writeProductHandler *productWriteHandler, variantHandler *variantHandler, attributeHandler *attributeHandler, imageHandler *imageHandler, categoryHandler *categoryHandler, deadLetterHandler *deadLetterHandler, poolStatsHandler *poolStatsHandler, cfg *config.Config) {
+	app.Get("/metrics", adaptor.HTTPHandler(metrics.Handler()))
+
 	// Setup routes
 	productGroup := app.Group("/product-service")
 
 	productGroup.Get("/products/:id", readProductHandler.GetByID)
 	productGroup.Get("/products", readProductHandler.GetListByQuery)
+	productGroup.Get("/products/:id/variants", variantHandler.GetByProductID)
+	productGroup.Get("/products/:id/images", imageHandler.GetByProductID)
+	productGroup.Get("/categories", categoryHandler.GetTree)
+	productGroup.Get("/categories/:category/attributes", attributeHandler.GetSchema)
+
+	// internal routes
+	internal := app.Group("/internal/product-service").Use(middleware.AuthInternal(cfg))
+
+	internal.Post("/categories", categoryHandler.Create)
+	internal.Put("/categories/:category/attributes", attributeHandler.SetSchema)
+	internal.Get("/stock/dead-letters", deadLetterHandler.List)
+	internal.Post("/stock/dead-letters/:sequence/replay", deadLetterHandler.Replay)
+	internal.Get("/db/stats", poolStatsHandler.Get)
 
 	// write product routes
 	writeProduct := app.Group("/product-service").Use(middleware.Auth(cfg.Jwt.SecretKey))
 
 	writeProduct.Post("/products", writeProductHandler.Create)
-	writeProduct.Patch("/products/:id", writeProductHandler.SetActiveStatus)
+	writeProduct.Put("/products/:id", writeProductHandler.Update)
+	writeProduct.Patch("/products/:id", writeProductHandler.Patch)
+	writeProduct.Patch("/products/:id/status", writeProductHandler.SetActiveStatus)
+
+	// variant routes
+	writeProduct.Post("/products/:id/variants", variantHandler.Create)
+	writeProduct.Put("/products/:id/variants/:variantId", variantHandler.Update)
+	writeProduct.Delete("/products/:id/variants/:variantId", variantHandler.Delete)
+
+	// image gallery routes
+	writeProduct.Post("/products/:id/images", imageHandler.Add)
+	writeProduct.Put("/products/:id/images/order", imageHandler.Reorder)
+	writeProduct.Put("/products/:id/images/:imageId/primary", imageHandler.SetPrimary)
+	writeProduct.Delete("/products/:id/images/:imageId", imageHandler.Delete)
 }
@@ -5,36 +5,156 @@ import (
 	"encoding/json"
 	"log/slog"
 	"product-service/app/domain"
+	"product-service/pkg/ctxutil"
+	"product-service/pkg/metrics"
+	"time"
 
 	"github.com/nats-io/nats.go/jetstream"
 )
 
+const (
+	defaultStockMaxDeliver    = 5
+	maxNakDelay               = time.Minute
+	deadLetterPublishAttempts = 3
+)
+
+// deadLetterRetryDelay is the first pause between dead letter publish retries
+// on the last delivery; it doubles with every retry.
+var deadLetterRetryDelay = time.Second
+
 type StockConsumerHandler struct {
 	stockUsecase domain.StockUsecase
+	maxDeliver   int
 }
 
-func NewStockConsumerHandler(stockUsecase domain.StockUsecase) *StockConsumerHandler {
+func NewStockConsumerHandler(stockUsecase domain.StockUsecase, maxDeliver int) *StockConsumerHandler {
+	if maxDeliver <= 0 {
+		maxDeliver = defaultStockMaxDeliver
+	}
 	return &StockConsumerHandler{
 		stockUsecase: stockUsecase,
+		maxDeliver:   maxDeliver,
 	}
 }
 
+// UpdateStock acks applied messages, naks transient failures with backoff
+// and dead-letters malformed messages as well as the last failed delivery.
 func (h *StockConsumerHandler) UpdateStock(msg jetstream.Msg) {
+	defer func(start time.Time) {
+		metrics.ConsumerProcessed(msg.Subject(), time.Since(start))
+	}(time.Now())
+
+	ctx := context.Background()
+	if reqID := msg.Headers().Get("X-Request-ID"); reqID != "" {
+		ctx = ctxutil.WithRequestID(ctx, reqID)
+	}
+
+	var deliveries, sequence uint64 = 1, 0
+	if meta, err := msg.Metadata(); err == nil {
+		deliveries, sequence = meta.NumDelivered, meta.Sequence.Stream
+	}
+
 	// Unmarshal the message
 	var stockMsg domain.StockMessage
 	if err := json.Unmarshal(msg.Data(), &stockMsg); err != nil {
-		slog.Error("[HandleStockMessage] Unmarshal failed", "error", err)
+		slog.ErrorContext(ctx, "[HandleStockMessage] Unmarshal failed", "error", err)
+		h.deadLetter(ctx, msg, sequence, deliveries, "malformed payload: "+err.Error())
+		return
+	}
+	if stockMsg.ProductID <= 0 || stockMsg.Available < 0 {
+		slog.ErrorContext(ctx, "[HandleStockMessage] Invalid message", "stock", stockMsg)
+		h.deadLetter(ctx, msg, sequence, deliveries, "invalid stock message")
 		return
 	}
 
-	if err := h.stockUsecase.UpdateStock(context.Background(), stockMsg); err != nil {
-		slog.Error("[HandleStockMessage] UpdateStock failed", "error", err)
+	if err := h.stockUsecase.UpdateStock(ctx, stockMsg); err != nil {
+		if deliveries >= uint64(h.maxDeliver) {
+			slog.ErrorContext(ctx, "[HandleStockMessage] UpdateStock failed, giving up", "deliveries", deliveries, "error", err)
+			h.deadLetter(ctx, msg, sequence, deliveries, "max deliveries reached: "+err.Error())
+			return
+		}
+
+		delay := nakDelay(deliveries)
+		slog.WarnContext(ctx, "[HandleStockMessage] UpdateStock failed, retrying", "deliveries", deliveries, "delay", delay, "error", err)
+		if err := msg.NakWithDelay(delay); err != nil {
+			slog.ErrorContext(ctx, "[HandleStockMessage] Nak failed", "error", err)
+			return
+		}
+		metrics.ConsumerOutcome(msg.Subject(), "nak")
 		return
 	}
 
-	msg.Ack()
+	if err := msg.Ack(); err != nil {
+		slog.ErrorContext(ctx, "[HandleStockMessage] Ack failed", "error", err)
+		return
+	}
+	metrics.ConsumerOutcome(msg.Subject(), "ack")
 
-	slog.Info("[HandleStockMessage] Stock updated successfully", "stock", stockMsg)
+	slog.InfoContext(ctx, "[HandleStockMessage] Stock updated successfully", "stock", stockMsg)
+}
+
+// PartitionKey returns the product a stock message belongs to, so the
+// consumer handles updates of one product one at a time. Messages without a
+// valid product ID return 0; they are dead-lettered anyway.
+func (h *StockConsumerHandler) PartitionKey(msg jetstream.Msg) uint64 {
+	var stockMsg struct {
+		ProductID int64 `json:"product_id"`
+	}
+	if err := json.Unmarshal(msg.Data(), &stockMsg); err != nil || stockMsg.ProductID <= 0 {
+		return 0
+	}
+	return uint64(stockMsg.ProductID)
+}
+
+// deadLetter moves msg to the dead letter subject and terminates it. If that
+// fails the message is naked so it is redelivered. JetStream does not
+// redeliver after the last delivery, so there the publish is retried inline
+// and, should it still fail, the message is left unacknowledged rather than
+// naked. It then stays in the stream and JetStream reports it with a max
+// deliveries advisory.
+func (h *StockConsumerHandler) deadLetter(ctx context.Context, msg jetstream.Msg, sequence, deliveries uint64, reason string) {
+	letter := &domain.DeadLetter{
+		Subject:          msg.Subject(),
+		OriginalSequence: sequence,
+		Reason:           reason,
+		Deliveries:       deliveries,
+		RequestID:        ctxutil.GetRequestID(ctx),
+		Data:             string(msg.Data()),
+	}
+	lastDelivery := deliveries >= uint64(h.maxDeliver)
+
+	err := h.stockUsecase.DeadLetter(ctx, letter)
+	for attempt := 1; err != nil && lastDelivery && attempt < deadLetterPublishAttempts; attempt++ {
+		slog.WarnContext(ctx, "[HandleStockMessage] dead letter publish failed, retrying", "attempt", attempt, "error", err)
+		if err := msg.InProgress(); err != nil {
+			slog.WarnContext(ctx, "[HandleStockMessage] InProgress failed", "error", err)
+		}
+		time.Sleep(deadLetterRetryDelay << (attempt - 1))
+		err = h.stockUsecase.DeadLetter(ctx, letter)
+	}
+	if err != nil {
+		if lastDelivery {
+			slog.ErrorContext(ctx, "[HandleStockMessage] dead letter publish failed on the last delivery, leaving the message unacknowledged",
+				"subject", msg.Subject(), "stream_sequence", sequence, "deliveries", deliveries, "reason", reason, "error", err)
+			return
+		}
+		if err := msg.NakWithDelay(nakDelay(deliveries)); err != nil {
+			slog.ErrorContext(ctx, "[HandleStockMessage] Nak failed", "error", err)
+			return
+		}
+		metrics.ConsumerOutcome(msg.Subject(), "nak")
+		return
+	}
+
+	if err := msg.TermWithReason(reason); err != nil {
+		slog.ErrorContext(ctx, "[HandleStockMessage] Term failed", "error", err)
+		return
+	}
+	metrics.ConsumerOutcome(msg.Subject(), "term")
+}
 
-	return
+// nakDelay doubles the redelivery delay with every delivery, up to a minute.
+func nakDelay(deliveries uint64) time.Duration {
+	delay := time.Second << min(deliveries-1, 6)
+	return min(delay, maxNakDelay)
 }
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"product-service/app/domain"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// recordingMsg records how a message was settled.
+type recordingMsg struct {
+	jetstream.Msg
+	naked, termed bool
+	inProgress    int
+}
+
+func (m *recordingMsg) Subject() string { return "stock.updated" }
+func (m *recordingMsg) Data() []byte    { return []byte(`{}`) }
+func (m *recordingMsg) InProgress() error {
+	m.inProgress++
+	return nil
+}
+func (m *recordingMsg) NakWithDelay(time.Duration) error {
+	m.naked = true
+	return nil
+}
+func (m *recordingMsg) TermWithReason(string) error {
+	m.termed = true
+	return nil
+}
+
+// failingDeadLetterUsecase fails the first failures dead letter publishes.
+type failingDeadLetterUsecase struct {
+	domain.StockUsecase
+	failures int
+	calls    int
+}
+
+func (u *failingDeadLetterUsecase) DeadLetter(ctx context.Context, letter *domain.DeadLetter) error {
+	u.calls++
+	if u.calls <= u.failures {
+		return errors.New("nats unavailable")
+	}
+	return nil
+}
+
+func TestDeadLetterPublishFailure(t *testing.T) {
+	defer func(delay time.Duration) { deadLetterRetryDelay = delay }(deadLetterRetryDelay)
+	deadLetterRetryDelay = time.Millisecond
+
+	tests := []struct {
+		name       string
+		deliveries uint64
+		failures   int
+		wantCalls  int
+		wantNak    bool
+		wantTerm   bool
+	}{
+		{name: "earlier delivery naks", deliveries: 2, failures: 1, wantCalls: 1, wantNak: true},
+		{name: "last delivery retries inline", deliveries: 5, failures: 2, wantCalls: 3, wantTerm: true},
+		{name: "last delivery leaves message unacknowledged", deliveries: 5, failures: deadLetterPublishAttempts, wantCalls: deadLetterPublishAttempts},
+		{name: "published", deliveries: 5, wantCalls: 1, wantTerm: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usecase := &failingDeadLetterUsecase{failures: tt.failures}
+			h := NewStockConsumerHandler(usecase, 5)
+			msg := &recordingMsg{}
+
+			h.deadLetter(context.Background(), msg, 7, tt.deliveries, "invalid stock message")
+
+			if usecase.calls != tt.wantCalls {
+				t.Fatalf("DeadLetter calls = %d, want %d", usecase.calls, tt.wantCalls)
+			}
+			if msg.naked != tt.wantNak {
+				t.Fatalf("naked = %v, want %v", msg.naked, tt.wantNak)
+			}
+			if msg.termed != tt.wantTerm {
+				t.Fatalf("termed = %v, want %v", msg.termed, tt.wantTerm)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"product-service/app/domain"
+	"product-service/app/handler/response"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type variantHandler struct {
+	variantUsecase domain.VariantUsecase
+	validator      *validator.Validate
+}
+
+func NewVariantHandler(variantUsecase domain.VariantUsecase, validator *validator.Validate) *variantHandler {
+	return &variantHandler{variantUsecase, validator}
+}
+
+func (h *variantHandler) GetByProductID(c *fiber.Ctx) error {
+	productID, err := paramID(c, "id")
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[variantHandler] GetByProductID", "params", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	variants, err := h.variantUsecase.GetByProductID(c.Context(), productID)
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[variantHandler] GetByProductID", "usecase", err)
+		status, response := response.FromError(err)
+		return c.Status(status).JSON(response)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.Success(variants))
+}
+
+func (h *variantHandler) Create(c *fiber.Ctx) error {
+	productID, err := paramID(c, "id")
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[variantHandler] Create", "params", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	var req domain.CreateVariantRequest
+	if err := c.BodyParser(&req); err != nil {
+		slog.ErrorContext(c.Context(), "[variantHandler] Create", "body", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		slog.ErrorContext(c.Context(), "[variantHandler] Create", "validation", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	res, err := h.variantUsecase.Create(c.Context(), productID, &req)
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[variantHandler] Create", "usecase", err)
+		status, response := response.FromError(err)
+		return c.Status(status).JSON(response)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(response.Success(res))
+}
+
+func (h *variantHandler) Update(c *fiber.Ctx) error {
+	productID, err := paramID(c, "id")
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[variantHandler] Update", "params", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	variantID, err := paramID(c, "variantId")
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[variantHandler] Update", "params", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	var req domain.UpdateVariantRequest
+	if err := c.BodyParser(&req); err != nil {
+		slog.ErrorContext(c.Context(), "[variantHandler] Update", "body", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		slog.ErrorContext(c.Context(), "[variantHandler] Update", "validation", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	res, err := h.variantUsecase.Update(c.Context(), productID, variantID, &req)
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[variantHandler] Update", "usecase", err)
+		status, response := response.FromError(err)
+		return c.Status(status).JSON(response)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.Success(res))
+}
+
+func (h *variantHandler) Delete(c *fiber.Ctx) error {
+	productID, err := paramID(c, "id")
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[variantHandler] Delete", "params", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	variantID, err := paramID(c, "variantId")
+	if err != nil {
+		slog.ErrorContext(c.Context(), "[variantHandler] Delete", "params", err)
+		return c.Status(fiber.StatusBadRequest).JSON(response.Error(domain.ErrBadRequest))
+	}
+
+	if err := h.variantUsecase.Delete(c.Context(), productID, variantID); err != nil {
+		slog.ErrorContext(c.Context(), "[variantHandler] Delete", "usecase", err)
+		status, response := response.FromError(err)
+		return c.Status(status).JSON(response)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response.Success[any](nil))
+}
+
+func paramID(c *fiber.Ctx, key string) (int64, error) {
+	idstr := c.Params(key)
+	if idstr == "" {
+		return 0, errors.New(key + " is empty")
+	}
+
+	id, err := strconv.ParseInt(idstr, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if id <= 0 {
+		return 0, errors.New(key + " must be positive")
+	}
+
+	return id, nil
+}
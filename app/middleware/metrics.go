@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"errors"
+	"product-service/pkg/metrics"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// unmatchedRoute labels requests no route matched, so random paths do not
+// create new series.
+const unmatchedRoute = "unmatched"
+
+// MetricsMiddleware records rate, errors and duration per route template.
+func MetricsMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		done := metrics.HTTPRequestStarted()
+
+		err := c.Next()
+
+		// the error handler sets the status after the middlewares returned
+		status := c.Response().StatusCode()
+		route := c.Route().Path
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				status = fiberErr.Code
+				if fiberErr.Code == fiber.StatusNotFound {
+					route = unmatchedRoute
+				}
+			}
+		}
+
+		done(c.Method(), route, status)
+		return err
+	}
+}
@@ -0,0 +1,137 @@
+package cacherepo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"product-service/app/domain"
+	"product-service/pkg/ctxutil"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const defaultWriteWindow = 5 * time.Second
+
+// fillProductScript caches a product unless it was written in the meantime,
+// so a read that raced with a write cannot put the old product back.
+var fillProductScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[2]) == 1 then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return 1
+`)
+
+// productReadRepository caches product details in Redis in front of another
+// domain.ProductReadRepository. List, count and facet queries are passed
+// through since their results depend on too many parameters to invalidate.
+//
+// Invalidate marks the product as written in Redis for writeWindow. Until the
+// marker expires every instance skips the cache for it and reads it from the
+// primary, so a lagging replica can neither serve nor cache the old product.
+type productReadRepository struct {
+	next        domain.ProductReadRepository
+	redis       *redis.Client
+	ttl         time.Duration
+	writeWindow time.Duration
+}
+
+func NewProductReadRepository(next domain.ProductReadRepository, redis *redis.Client, ttl, writeWindow time.Duration) domain.CachedProductReadRepository {
+	if writeWindow <= 0 {
+		writeWindow = defaultWriteWindow
+	}
+	return &productReadRepository{
+		next:        next,
+		redis:       redis,
+		ttl:         ttl,
+		writeWindow: writeWindow,
+	}
+}
+
+func (r *productReadRepository) GetByID(ctx context.Context, id int64) (*domain.Product, error) {
+	values, err := r.redis.MGet(ctx, r.key(id), r.writtenKey(id)).Result()
+	if err != nil {
+		// Without the marker it is unknown whether the product was just
+		// written, so read through without caching.
+		slog.WarnContext(ctx, "[cacheProductReadRepository] GetByID", "productID", id, "error", err)
+		return r.next.GetByID(ctx, id)
+	}
+	if values[1] != nil {
+		slog.InfoContext(ctx, "[cacheProductReadRepository] GetByID recently written, reading from primary", "productID", id)
+		return r.next.GetByID(ctxutil.WithPrimaryRead(ctx), id)
+	}
+	if r.ttl <= 0 {
+		return r.next.GetByID(ctx, id)
+	}
+
+	if cached, ok := values[0].(string); ok {
+		var product domain.Product
+		if err := json.Unmarshal([]byte(cached), &product); err == nil {
+			slog.InfoContext(ctx, "[cacheProductReadRepository] GetByID cache hit", "productID", id)
+			return &product, nil
+		}
+		slog.WarnContext(ctx, "[cacheProductReadRepository] GetByID invalid cache entry", "productID", id, "error", err)
+	}
+	slog.InfoContext(ctx, "[cacheProductReadRepository] GetByID cache miss", "productID", id)
+
+	product, err := r.next.GetByID(ctx, id)
+	if err != nil || product == nil {
+		return product, err
+	}
+
+	raw, err := json.Marshal(product)
+	if err != nil {
+		slog.WarnContext(ctx, "[cacheProductReadRepository] GetByID json.Marshal", "productID", id, "error", err)
+		return product, nil
+	}
+	keys := []string{r.key(id), r.writtenKey(id)}
+	if err := fillProductScript.Run(ctx, r.redis, keys, raw, r.ttl.Milliseconds()).Err(); err != nil {
+		slog.WarnContext(ctx, "[cacheProductReadRepository] GetByID cache set", "productID", id, "error", err)
+	}
+
+	return product, nil
+}
+
+func (r *productReadRepository) GetListByQuery(ctx context.Context, query domain.ProductQuery) ([]*domain.Product, error) {
+	return r.next.GetListByQuery(ctx, query)
+}
+
+func (r *productReadRepository) CountByQuery(ctx context.Context, query domain.ProductQuery) (int64, error) {
+	return r.next.CountByQuery(ctx, query)
+}
+
+func (r *productReadRepository) GetFacets(ctx context.Context, query domain.ProductQuery, facets []string) (*domain.ProductFacets, error) {
+	return r.next.GetFacets(ctx, query, facets)
+}
+
+// Invalidate marks the product as written before dropping it from the cache,
+// so no instance refills it from a replica in between. It also passes the
+// invalidation on when the next repository wants to know about writes.
+func (r *productReadRepository) Invalidate(ctx context.Context, id int64) error {
+	if next, ok := r.next.(domain.ProductCacheInvalidator); ok {
+		if err := next.Invalidate(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	if err := r.redis.Set(ctx, r.writtenKey(id), 1, r.writeWindow).Err(); err != nil {
+		slog.ErrorContext(ctx, "[cacheProductReadRepository] Invalidate mark written", "productID", id, "error", err)
+		return err
+	}
+	if err := r.redis.Del(ctx, r.key(id)).Err(); err != nil {
+		slog.ErrorContext(ctx, "[cacheProductReadRepository] Invalidate", "productID", id, "error", err)
+		return err
+	}
+	slog.InfoContext(ctx, "[cacheProductReadRepository] Invalidate", "productID", id)
+	return nil
+}
+
+func (r *productReadRepository) key(id int64) string {
+	return fmt.Sprintf("product:%d", id)
+}
+
+func (r *productReadRepository) writtenKey(id int64) string {
+	return fmt.Sprintf("product:%d:written", id)
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"product-service/app/domain"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type attributeRepository struct {
+	conn      *pgxpool.Pool
+	txManager domain.TransactionManager
+}
+
+func NewAttributeRepository(db *pgxpool.Pool, txManager domain.TransactionManager) domain.AttributeRepository {
+	return &attributeRepository{db, txManager}
+}
+
+func (r *attributeRepository) GetByCategory(ctx context.Context, category string) ([]*domain.AttributeDefinition, error) {
+	query := `SELECT id, category, code, name, type, required, unit, options FROM category_attributes WHERE category = $1 ORDER BY id`
+	rows, err := conn(ctx, r.conn).Query(ctx, query, category)
+	if err != nil {
+		slog.ErrorContext(ctx, "[attributeRepository] GetByCategory", "query", err)
+		return nil, domain.ErrInternal
+	}
+	defer rows.Close()
+
+	var attributes []*domain.AttributeDefinition
+	for rows.Next() {
+		var attribute domain.AttributeDefinition
+		var options []byte
+		if err := rows.Scan(&attribute.ID, &attribute.Category, &attribute.Code, &attribute.Name, &attribute.Type, &attribute.Required, &attribute.Unit, &options); err != nil {
+			slog.ErrorContext(ctx, "[attributeRepository] GetByCategory", "scan", err)
+			return nil, domain.ErrInternal
+		}
+		if err := json.Unmarshal(options, &attribute.Options); err != nil {
+			slog.ErrorContext(ctx, "[attributeRepository] GetByCategory", "unmarshal", err)
+			return nil, domain.ErrInternal
+		}
+		attributes = append(attributes, &attribute)
+	}
+
+	return attributes, nil
+}
+
+// ReplaceForCategory swaps the whole attribute schema of a category in one
+// transaction. Values already stored on products are left untouched.
+func (r *attributeRepository) ReplaceForCategory(ctx context.Context, category string, attributes []*domain.AttributeDefinition) error {
+	return r.txManager.WithTransaction(ctx, func(ctx context.Context) error {
+		tx := conn(ctx, r.conn)
+		if _, err := tx.Exec(ctx, `DELETE FROM category_attributes WHERE category = $1`, category); err != nil {
+			slog.ErrorContext(ctx, "[attributeRepository] ReplaceForCategory", "delete", err)
+			return domain.ErrInternal
+		}
+
+		query := `INSERT INTO category_attributes (category, code, name, type, required, unit, options)
+			VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+		for _, attribute := range attributes {
+			options, err := json.Marshal(attribute.Options)
+			if err != nil {
+				slog.ErrorContext(ctx, "[attributeRepository] ReplaceForCategory", "json.Marshal", err)
+				return domain.ErrInternal
+			}
+
+			err = tx.QueryRow(ctx, query,
+				category,
+				attribute.Code,
+				attribute.Name,
+				attribute.Type,
+				attribute.Required,
+				attribute.Unit,
+				options).
+				Scan(&attribute.ID)
+			if err != nil {
+				if isUniqueViolation(err) {
+					return domain.ErrConflict
+				}
+				slog.ErrorContext(ctx, "[attributeRepository] ReplaceForCategory", "insert", err)
+				return domain.ErrInternal
+			}
+			attribute.Category = category
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"product-service/app/domain"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type categoryRepository struct {
+	conn *pgxpool.Pool
+}
+
+func NewCategoryRepository(db *pgxpool.Pool) domain.CategoryRepository {
+	return &categoryRepository{db}
+}
+
+func (r *categoryRepository) GetAll(ctx context.Context) ([]*domain.Category, error) {
+	query := `SELECT id, parent_id, name, slug, created_at, updated_at FROM categories ORDER BY name`
+	rows, err := conn(ctx, r.conn).Query(ctx, query)
+	if err != nil {
+		slog.ErrorContext(ctx, "[categoryRepository] GetAll", "query", err)
+		return nil, domain.ErrInternal
+	}
+	defer rows.Close()
+
+	var categories []*domain.Category
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			slog.ErrorContext(ctx, "[categoryRepository] GetAll", "scan", err)
+			return nil, domain.ErrInternal
+		}
+		categories = append(categories, category)
+	}
+
+	return categories, nil
+}
+
+func (r *categoryRepository) GetByID(ctx context.Context, id int64) (*domain.Category, error) {
+	query := `SELECT id, parent_id, name, slug, created_at, updated_at FROM categories WHERE id = $1`
+	category, err := scanCategory(conn(ctx, r.conn).QueryRow(ctx, query, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, domain.ErrNotFound
+		}
+		slog.ErrorContext(ctx, "[categoryRepository] GetByID", "scan", err)
+		return nil, domain.ErrInternal
+	}
+
+	return category, nil
+}
+
+func (r *categoryRepository) GetBySlug(ctx context.Context, slug string) (*domain.Category, error) {
+	query := `SELECT id, parent_id, name, slug, created_at, updated_at FROM categories WHERE slug = $1`
+	category, err := scanCategory(conn(ctx, r.conn).QueryRow(ctx, query, slug))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, domain.ErrNotFound
+		}
+		slog.ErrorContext(ctx, "[categoryRepository] GetBySlug", "scan", err)
+		return nil, domain.ErrInternal
+	}
+
+	return category, nil
+}
+
+func (r *categoryRepository) Create(ctx context.Context, category *domain.Category) error {
+	query := `INSERT INTO categories (parent_id, name, slug) VALUES ($1, $2, $3) RETURNING id, created_at, updated_at`
+	err := conn(ctx, r.conn).QueryRow(ctx, query, category.ParentID, category.Name, category.Slug).
+		Scan(&category.ID, &category.CreatedAt, &category.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.ErrConflict
+		}
+		slog.ErrorContext(ctx, "[categoryRepository] Create", "scan", err)
+		return domain.ErrInternal
+	}
+
+	return nil
+}
+
+func scanCategory(row rowScanner) (*domain.Category, error) {
+	var category domain.Category
+	var parentID sql.NullInt64
+	if err := row.Scan(&category.ID, &parentID, &category.Name, &category.Slug, &category.CreatedAt, &category.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		category.ParentID = &parentID.Int64
+	}
+	return &category, nil
+}
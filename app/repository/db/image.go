@@ -0,0 +1,333 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"product-service/app/domain"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type imageRepository struct {
+	conn      *pgxpool.Pool
+	txManager domain.TransactionManager
+}
+
+func NewImageRepository(db *pgxpool.Pool, txManager domain.TransactionManager) domain.ImageRepository {
+	return &imageRepository{db, txManager}
+}
+
+const imageColumns = `id, product_id, url, alt_text, position, is_primary, created_at`
+
+func (r *imageRepository) GetByProductID(ctx context.Context, productID int64) ([]*domain.ProductImage, error) {
+	query := `SELECT ` + imageColumns + ` FROM product_images WHERE product_id = $1 ORDER BY position, id`
+	rows, err := conn(ctx, r.conn).Query(ctx, query, productID)
+	if err != nil {
+		slog.ErrorContext(ctx, "[imageRepository] GetByProductID", "query", err)
+		return nil, domain.ErrInternal
+	}
+	defer rows.Close()
+
+	var images []*domain.ProductImage
+	for rows.Next() {
+		image, err := scanImage(rows)
+		if err != nil {
+			slog.ErrorContext(ctx, "[imageRepository] GetByProductID", "scan", err)
+			return nil, domain.ErrInternal
+		}
+		images = append(images, image)
+	}
+
+	return images, nil
+}
+
+func (r *imageRepository) GetByProductIDs(ctx context.Context, productIDs []int64) (map[int64][]*domain.ProductImage, error) {
+	images := make(map[int64][]*domain.ProductImage, len(productIDs))
+	if len(productIDs) == 0 {
+		return images, nil
+	}
+
+	query := `SELECT ` + imageColumns + ` FROM product_images WHERE product_id = ANY($1) ORDER BY product_id, position, id`
+	rows, err := conn(ctx, r.conn).Query(ctx, query, productIDs)
+	if err != nil {
+		slog.ErrorContext(ctx, "[imageRepository] GetByProductIDs", "query", err)
+		return nil, domain.ErrInternal
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		image, err := scanImage(rows)
+		if err != nil {
+			slog.ErrorContext(ctx, "[imageRepository] GetByProductIDs", "scan", err)
+			return nil, domain.ErrInternal
+		}
+		images[image.ProductID] = append(images[image.ProductID], image)
+	}
+
+	return images, nil
+}
+
+// Create appends the image to the end of the gallery. The first image of a
+// product always becomes the primary one.
+func (r *imageRepository) Create(ctx context.Context, image *domain.ProductImage) error {
+	err := r.inTx(ctx, func(tx executor) error {
+		if err := lockProduct(ctx, tx, image.ProductID); err != nil {
+			return err
+		}
+
+		var count int
+		query := `SELECT COUNT(*), COALESCE(MAX(position) + 1, 0) FROM product_images WHERE product_id = $1`
+		if err := tx.QueryRow(ctx, query, image.ProductID).Scan(&count, &image.Position); err != nil {
+			return fmt.Errorf("count images: %w", err)
+		}
+		if count == 0 {
+			image.Primary = true
+		}
+		if image.Primary {
+			if err := unsetPrimary(ctx, tx, image.ProductID); err != nil {
+				return err
+			}
+		}
+
+		query = `INSERT INTO product_images (product_id, url, alt_text, position, is_primary)
+			VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
+		err := tx.QueryRow(ctx, query, image.ProductID, image.URL, image.AltText, image.Position, image.Primary).
+			Scan(&image.ID, &image.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("insert image: %w", err)
+		}
+
+		if image.Primary {
+			return syncPrimaryImage(ctx, tx, image.ProductID, image.URL)
+		}
+		return nil
+	})
+	if err != nil {
+		return r.mapError(ctx, "Create", err)
+	}
+
+	return nil
+}
+
+func (r *imageRepository) SetPrimary(ctx context.Context, productID, id int64) error {
+	err := r.inTx(ctx, func(tx executor) error {
+		if err := lockProduct(ctx, tx, productID); err != nil {
+			return err
+		}
+
+		var url string
+		query := `SELECT url FROM product_images WHERE id = $1 AND product_id = $2`
+		if err := tx.QueryRow(ctx, query, id, productID).Scan(&url); err != nil {
+			if err == pgx.ErrNoRows {
+				return domain.ErrNotFound
+			}
+			return fmt.Errorf("select image: %w", err)
+		}
+
+		if err := markPrimary(ctx, tx, productID, id); err != nil {
+			return err
+		}
+		return syncPrimaryImage(ctx, tx, productID, url)
+	})
+	if err != nil {
+		return r.mapError(ctx, "SetPrimary", err)
+	}
+
+	return nil
+}
+
+// SetPrimaryByURL marks the gallery image with the given URL as primary,
+// appending it first when the gallery does not have it yet. Unlike SetPrimary
+// it leaves products.image_url alone, the caller has already stored it.
+func (r *imageRepository) SetPrimaryByURL(ctx context.Context, productID int64, url string) error {
+	err := r.inTx(ctx, func(tx executor) error {
+		if err := lockProduct(ctx, tx, productID); err != nil {
+			return err
+		}
+
+		var id int64
+		query := `SELECT id FROM product_images WHERE product_id = $1 AND url = $2 ORDER BY position LIMIT 1`
+		err := tx.QueryRow(ctx, query, productID, url).Scan(&id)
+		if err == pgx.ErrNoRows {
+			query = `INSERT INTO product_images (product_id, url, alt_text, position, is_primary)
+				SELECT $1, $2, '', COALESCE(MAX(position) + 1, 0), false FROM product_images WHERE product_id = $1
+				RETURNING id`
+			err = tx.QueryRow(ctx, query, productID, url).Scan(&id)
+		}
+		if err != nil {
+			return fmt.Errorf("resolve image: %w", err)
+		}
+
+		return markPrimary(ctx, tx, productID, id)
+	})
+	if err != nil {
+		return r.mapError(ctx, "SetPrimaryByURL", err)
+	}
+
+	return nil
+}
+
+// Reorder sets the gallery order. imageIDs must list every image of the
+// product exactly once.
+func (r *imageRepository) Reorder(ctx context.Context, productID int64, imageIDs []int64) error {
+	err := r.inTx(ctx, func(tx executor) error {
+		if err := lockProduct(ctx, tx, productID); err != nil {
+			return err
+		}
+
+		rows, err := tx.Query(ctx, `SELECT id FROM product_images WHERE product_id = $1`, productID)
+		if err != nil {
+			return fmt.Errorf("select images: %w", err)
+		}
+		var existing []int64
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return fmt.Errorf("scan image id: %w", err)
+			}
+			existing = append(existing, id)
+		}
+		rows.Close()
+
+		requested := slices.Clone(imageIDs)
+		slices.Sort(existing)
+		slices.Sort(requested)
+		if !slices.Equal(existing, requested) {
+			return fmt.Errorf("%w: image_ids must contain every image of the product exactly once", domain.ErrValidation)
+		}
+
+		for position, id := range imageIDs {
+			query := `UPDATE product_images SET position = $1 WHERE id = $2 AND product_id = $3`
+			if _, err := tx.Exec(ctx, query, position, id, productID); err != nil {
+				return fmt.Errorf("update position: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return r.mapError(ctx, "Reorder", err)
+	}
+
+	return nil
+}
+
+// Delete removes an image from the gallery. When the primary image goes away
+// the next one in order is promoted. The last image cannot be removed.
+func (r *imageRepository) Delete(ctx context.Context, productID, id int64) error {
+	err := r.inTx(ctx, func(tx executor) error {
+		if err := lockProduct(ctx, tx, productID); err != nil {
+			return err
+		}
+
+		var primary bool
+		query := `SELECT is_primary FROM product_images WHERE id = $1 AND product_id = $2`
+		if err := tx.QueryRow(ctx, query, id, productID).Scan(&primary); err != nil {
+			if err == pgx.ErrNoRows {
+				return domain.ErrNotFound
+			}
+			return fmt.Errorf("select image: %w", err)
+		}
+
+		var count int
+		if err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM product_images WHERE product_id = $1`, productID).Scan(&count); err != nil {
+			return fmt.Errorf("count images: %w", err)
+		}
+		if count <= 1 {
+			return fmt.Errorf("%w: a product needs at least one image", domain.ErrValidation)
+		}
+
+		if _, err := tx.Exec(ctx, `DELETE FROM product_images WHERE id = $1`, id); err != nil {
+			return fmt.Errorf("delete image: %w", err)
+		}
+		if !primary {
+			return nil
+		}
+
+		var nextID int64
+		var nextURL string
+		query = `SELECT id, url FROM product_images WHERE product_id = $1 ORDER BY position, id LIMIT 1`
+		if err := tx.QueryRow(ctx, query, productID).Scan(&nextID, &nextURL); err != nil {
+			return fmt.Errorf("select next primary: %w", err)
+		}
+		if err := markPrimary(ctx, tx, productID, nextID); err != nil {
+			return err
+		}
+		return syncPrimaryImage(ctx, tx, productID, nextURL)
+	})
+	if err != nil {
+		return r.mapError(ctx, "Delete", err)
+	}
+
+	return nil
+}
+
+// inTx runs fn in the transaction carried by ctx, or in a new one, so the
+// product lock is held for the whole gallery change.
+func (r *imageRepository) inTx(ctx context.Context, fn func(tx executor) error) error {
+	return r.txManager.WithTransaction(ctx, func(ctx context.Context) error {
+		return fn(conn(ctx, r.conn))
+	})
+}
+
+// mapError passes domain errors through and hides everything else behind
+// ErrInternal after logging it.
+func (r *imageRepository) mapError(ctx context.Context, op string, err error) error {
+	for _, domainErr := range []error{domain.ErrNotFound, domain.ErrValidation} {
+		if errors.Is(err, domainErr) {
+			return err
+		}
+	}
+	slog.ErrorContext(ctx, "[imageRepository] "+op, "error", err)
+	return domain.ErrInternal
+}
+
+func lockProduct(ctx context.Context, tx executor, productID int64) error {
+	var id int64
+	if err := tx.QueryRow(ctx, `SELECT id FROM products WHERE id = $1 FOR UPDATE`, productID).Scan(&id); err != nil {
+		if err == pgx.ErrNoRows {
+			return domain.ErrNotFound
+		}
+		return fmt.Errorf("lock product: %w", err)
+	}
+	return nil
+}
+
+func unsetPrimary(ctx context.Context, tx executor, productID int64) error {
+	if _, err := tx.Exec(ctx, `UPDATE product_images SET is_primary = false WHERE product_id = $1 AND is_primary`, productID); err != nil {
+		return fmt.Errorf("unset primary: %w", err)
+	}
+	return nil
+}
+
+func markPrimary(ctx context.Context, tx executor, productID, id int64) error {
+	if err := unsetPrimary(ctx, tx, productID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE product_images SET is_primary = true WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("set primary: %w", err)
+	}
+	return nil
+}
+
+// syncPrimaryImage mirrors the primary gallery image onto products.image_url
+// and bumps the product version so outstanding ETags are invalidated.
+func syncPrimaryImage(ctx context.Context, tx executor, productID int64, url string) error {
+	query := `UPDATE products SET image_url = $1, updated_at = NOW(), version = version + 1 WHERE id = $2 AND image_url IS DISTINCT FROM $1`
+	if _, err := tx.Exec(ctx, query, url, productID); err != nil {
+		return fmt.Errorf("sync primary image: %w", err)
+	}
+	return nil
+}
+
+func scanImage(row rowScanner) (*domain.ProductImage, error) {
+	var image domain.ProductImage
+	if err := row.Scan(&image.ID, &image.ProductID, &image.URL, &image.AltText, &image.Position, &image.Primary, &image.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &image, nil
+}
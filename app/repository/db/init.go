@@ -1,16 +1,81 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"product-service/app/domain"
 	"product-service/config"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
+const (
+	defaultMaxConns          = 10
+	defaultMaxConnLifetime   = 30 * time.Minute
+	defaultMaxConnIdleTime   = 5 * time.Minute
+	defaultHealthCheckPeriod = time.Minute
+)
+
+// NewPool connects the pool the repositories run on.
+func NewPool(ctx context.Context, cfg config.DbConfig) (*pgxpool.Pool, error) {
+	pool, err := newPool(ctx, dsn(cfg), cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("ping db: %w", err)
+	}
+
+	return pool, nil
+}
+
+// NewReplicaPool creates the read replica pool. Unlike NewPool it does not
+// require the replica to be up, reads fall back to the primary until it is.
+func NewReplicaPool(ctx context.Context, cfg config.DbConfig) (*pgxpool.Pool, error) {
+	return newPool(ctx, cfg.ReplicaDSN, cfg)
+}
+
+// NewPostgres opens a database/sql handle on the primary, for the migrator
+// which needs one.
 func NewPostgres(cfg config.DbConfig) (*sql.DB, error) {
-	dsn := fmt.Sprintf(
+	db, err := sql.Open("pgx", dsn(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("open db: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("ping db: %w", err)
+	}
+
+	return db, nil
+}
+
+// PoolStats snapshots the counters of pool.
+func PoolStats(pool *pgxpool.Pool) domain.DBPoolStats {
+	stat := pool.Stat()
+	return domain.DBPoolStats{
+		MaxConns:                stat.MaxConns(),
+		TotalConns:              stat.TotalConns(),
+		AcquiredConns:           stat.AcquiredConns(),
+		IdleConns:               stat.IdleConns(),
+		ConstructingConns:       stat.ConstructingConns(),
+		AcquireCount:            stat.AcquireCount(),
+		AcquireDurationMs:       stat.AcquireDuration().Milliseconds(),
+		EmptyAcquireCount:       stat.EmptyAcquireCount(),
+		CanceledAcquireCount:    stat.CanceledAcquireCount(),
+		NewConnsCount:           stat.NewConnsCount(),
+		MaxLifetimeDestroyCount: stat.MaxLifetimeDestroyCount(),
+		MaxIdleDestroyCount:     stat.MaxIdleDestroyCount(),
+	}
+}
+
+func dsn(cfg config.DbConfig) string {
+	return fmt.Sprintf(
 		"user=%s password=%s host=%s port=%s dbname=%s sslmode=%s TimeZone=UTC",
 		cfg.Username,
 		cfg.Password,
@@ -19,19 +84,41 @@ func NewPostgres(cfg config.DbConfig) (*sql.DB, error) {
 		cfg.DbName,
 		cfg.SSLMode,
 	)
+}
+
+func newPool(ctx context.Context, dsn string, cfg config.DbConfig) (*pgxpool.Pool, error) {
+	// pgx validates the mode when it parses the DSN.
+	if cfg.StatementCacheMode != "" {
+		dsn += " default_query_exec_mode=" + cfg.StatementCacheMode
+	}
 
-	db, err := sql.Open("pgx", dsn)
+	poolConfig, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
-		return nil, fmt.Errorf("open db: %w", err)
+		return nil, fmt.Errorf("parse db config: %w", err)
 	}
 
-	db.SetMaxOpenConns(10)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(30 * time.Minute)
+	poolConfig.MaxConns = defaultMaxConns
+	if cfg.MaxConns > 0 {
+		poolConfig.MaxConns = cfg.MaxConns
+	}
+	poolConfig.MinConns = cfg.MinConns
+	poolConfig.MaxConnLifetime = defaultMaxConnLifetime
+	if cfg.MaxConnLifetimeSeconds > 0 {
+		poolConfig.MaxConnLifetime = time.Duration(cfg.MaxConnLifetimeSeconds) * time.Second
+	}
+	poolConfig.MaxConnIdleTime = defaultMaxConnIdleTime
+	if cfg.MaxConnIdleSeconds > 0 {
+		poolConfig.MaxConnIdleTime = time.Duration(cfg.MaxConnIdleSeconds) * time.Second
+	}
+	poolConfig.HealthCheckPeriod = defaultHealthCheckPeriod
+	if cfg.HealthCheckPeriodSeconds > 0 {
+		poolConfig.HealthCheckPeriod = time.Duration(cfg.HealthCheckPeriodSeconds) * time.Second
+	}
 
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("ping db: %w", err)
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("create pool: %w", err)
 	}
 
-	return db, nil
+	return pool, nil
 }
@@ -0,0 +1,143 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"product-service/config"
+	"product-service/migrations"
+
+	"github.com/golang-migrate/migrate/v4"
+	pgxmigrate "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+const defaultMigrationLockTimeout = time.Minute
+
+// Migrator applies the migrations embedded in the binary. It holds a
+// Postgres advisory lock while migrating, so replicas booting at the same
+// time apply every migration exactly once.
+type Migrator struct {
+	m *migrate.Migrate
+}
+
+// MigrationStatus is a migration known to the binary and whether the
+// database has it applied.
+type MigrationStatus struct {
+	Version uint
+	Applied bool
+}
+
+// NewMigrator opens a connection of its own, which Close releases.
+func NewMigrator(cfg config.DbConfig) (*Migrator, error) {
+	database, err := NewPostgres(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newMigrator(database, time.Duration(cfg.MigrationLockTimeoutSeconds)*time.Second)
+}
+
+// newMigrator takes over database, which Close releases.
+func newMigrator(database *sql.DB, lockTimeout time.Duration) (*Migrator, error) {
+	driver, err := pgxmigrate.WithInstance(database, &pgxmigrate.Config{})
+	if err != nil {
+		database.Close()
+		return nil, fmt.Errorf("migration driver: %w", err)
+	}
+
+	source, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		driver.Close()
+		return nil, fmt.Errorf("migration source: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "pgx", driver)
+	if err != nil {
+		driver.Close()
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+
+	m.LockTimeout = lockTimeout
+	if m.LockTimeout <= 0 {
+		m.LockTimeout = defaultMigrationLockTimeout
+	}
+
+	return &Migrator{m: m}, nil
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up() error {
+	if err := m.m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+	return nil
+}
+
+// Down rolls back the given number of applied migrations.
+func (m *Migrator) Down(steps int) error {
+	if steps <= 0 {
+		return fmt.Errorf("steps must be positive, got %d", steps)
+	}
+	if err := m.m.Steps(-steps); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+	return nil
+}
+
+// Version returns the applied version, zero when nothing is applied. A dirty
+// database has a migration that failed half way and must be fixed by hand.
+func (m *Migrator) Version() (version uint, dirty bool, err error) {
+	version, dirty, err = m.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+// Status lists the embedded migrations in order.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	current, _, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := MigrationVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(versions))
+	for _, version := range versions {
+		statuses = append(statuses, MigrationStatus{Version: version, Applied: version <= current})
+	}
+	return statuses, nil
+}
+
+func (m *Migrator) Close() error {
+	sourceErr, dbErr := m.m.Close()
+	return errors.Join(sourceErr, dbErr)
+}
+
+// MigrationVersions returns the versions of the embedded migrations in
+// ascending order.
+func MigrationVersions() ([]uint, error) {
+	source, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+
+	var versions []uint
+	version, err := source.First()
+	for err == nil {
+		versions = append(versions, version)
+		version, err = source.Next(version)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return versions, nil
+}
@@ -0,0 +1,36 @@
+package db
+
+import (
+	"io/fs"
+	"product-service/migrations"
+	"strings"
+	"testing"
+)
+
+// Migrations are numbered in the order the schema changes landed, with no
+// gaps, and each one can be rolled back.
+func TestMigrationsAreSequentialAndReversible(t *testing.T) {
+	versions, err := MigrationVersions()
+	if err != nil {
+		t.Fatalf("MigrationVersions: %v", err)
+	}
+	for i, version := range versions {
+		if version != uint(i+1) {
+			t.Fatalf("migration %d has version %d, want %d", i, version, i+1)
+		}
+	}
+
+	ups, err := fs.Glob(migrations.FS, "*.up.sql")
+	if err != nil {
+		t.Fatalf("glob: %v", err)
+	}
+	if len(ups) != len(versions) {
+		t.Fatalf("%d up migrations for %d versions", len(ups), len(versions))
+	}
+	for _, up := range ups {
+		down := strings.TrimSuffix(up, ".up.sql") + ".down.sql"
+		if _, err := fs.Stat(migrations.FS, down); err != nil {
+			t.Fatalf("%s has no %s", up, down)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"product-service/app/domain"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type outboxRepository struct {
+	conn *pgxpool.Pool
+}
+
+func NewOutboxRepository(db *pgxpool.Pool) domain.OutboxRepository {
+	return &outboxRepository{db}
+}
+
+// Enqueue must join the transaction of the change the message belongs to,
+// otherwise the message could outlive a rolled back change or get lost.
+func (r *outboxRepository) Enqueue(ctx context.Context, topic string, payload any) error {
+	if !inTransaction(ctx) {
+		slog.ErrorContext(ctx, "[outboxRepository] Enqueue", "error", "called outside a transaction")
+		return domain.ErrInternal
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		slog.ErrorContext(ctx, "[outboxRepository] Enqueue", "json.Marshal", err)
+		return domain.ErrInternal
+	}
+
+	query := `INSERT INTO outbox (topic, payload) VALUES ($1, $2)`
+	if _, err := conn(ctx, r.conn).Exec(ctx, query, topic, data); err != nil {
+		slog.ErrorContext(ctx, "[outboxRepository] Enqueue", "exec", err)
+		return domain.ErrInternal
+	}
+
+	return nil
+}
+
+func (r *outboxRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]*domain.OutboxMessage, error) {
+	query := `UPDATE outbox SET attempts = attempts + 1, next_attempt_at = NOW() + $2 * INTERVAL '1 millisecond'
+		WHERE id IN (
+			SELECT id FROM outbox
+			WHERE delivered_at IS NULL AND dead_at IS NULL AND next_attempt_at <= NOW()
+			ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, topic, payload, attempts, last_error, created_at`
+	rows, err := conn(ctx, r.conn).Query(ctx, query, limit, lease.Milliseconds())
+	if err != nil {
+		slog.ErrorContext(ctx, "[outboxRepository] Claim", "query", err)
+		return nil, domain.ErrInternal
+	}
+	defer rows.Close()
+
+	var messages []*domain.OutboxMessage
+	for rows.Next() {
+		var message domain.OutboxMessage
+		var payload []byte
+		if err := rows.Scan(&message.ID, &message.Topic, &payload, &message.Attempts, &message.LastError, &message.CreatedAt); err != nil {
+			slog.ErrorContext(ctx, "[outboxRepository] Claim", "scan", err)
+			return nil, domain.ErrInternal
+		}
+		message.Payload = payload
+		messages = append(messages, &message)
+	}
+
+	return messages, nil
+}
+
+func (r *outboxRepository) MarkDelivered(ctx context.Context, id int64) error {
+	query := `UPDATE outbox SET delivered_at = NOW(), last_error = '' WHERE id = $1`
+	if _, err := conn(ctx, r.conn).Exec(ctx, query, id); err != nil {
+		slog.ErrorContext(ctx, "[outboxRepository] MarkDelivered", "exec", err)
+		return domain.ErrInternal
+	}
+	return nil
+}
+
+func (r *outboxRepository) MarkFailed(ctx context.Context, id int64, retryAt time.Time, cause error) error {
+	query := `UPDATE outbox SET next_attempt_at = $2, last_error = $3 WHERE id = $1`
+	if _, err := conn(ctx, r.conn).Exec(ctx, query, id, retryAt, cause.Error()); err != nil {
+		slog.ErrorContext(ctx, "[outboxRepository] MarkFailed", "exec", err)
+		return domain.ErrInternal
+	}
+	return nil
+}
+
+func (r *outboxRepository) MarkDead(ctx context.Context, id int64, cause error) error {
+	query := `UPDATE outbox SET dead_at = NOW(), last_error = $2 WHERE id = $1`
+	if _, err := conn(ctx, r.conn).Exec(ctx, query, id, cause.Error()); err != nil {
+		slog.ErrorContext(ctx, "[outboxRepository] MarkDead", "exec", err)
+		return domain.ErrInternal
+	}
+	return nil
+}
+
+func (r *outboxRepository) DeleteFinished(ctx context.Context, before time.Time, limit int) (int64, error) {
+	query := `DELETE FROM outbox WHERE id IN (
+			SELECT id FROM outbox WHERE delivered_at < $1 OR dead_at < $1 LIMIT $2
+		)`
+	tag, err := conn(ctx, r.conn).Exec(ctx, query, before, limit)
+	if err != nil {
+		slog.ErrorContext(ctx, "[outboxRepository] DeleteFinished", "exec", err)
+		return 0, domain.ErrInternal
+	}
+	return tag.RowsAffected(), nil
+}
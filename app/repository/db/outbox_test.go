@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"product-service/app/domain"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// newTestOutbox empties the outbox and enqueues count messages.
+func newTestOutbox(t *testing.T, pool *pgxpool.Pool, count int) domain.OutboxRepository {
+	t.Helper()
+	ctx := context.Background()
+
+	if _, err := pool.Exec(ctx, `TRUNCATE outbox`); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+
+	repo := NewOutboxRepository(pool)
+	err := NewTransactionManager(pool).WithTransaction(ctx, func(ctx context.Context) error {
+		for i := 0; i < count; i++ {
+			if err := repo.Enqueue(ctx, domain.OutboxTopicInitStock, domain.InitStockRequest{ProductID: int64(i + 1)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	return repo
+}
+
+func TestOutboxClaimSkipsLockedAndLeasedMessages(t *testing.T) {
+	pool := testPool(t)
+	repo := newTestOutbox(t, pool, 3)
+	ctx := context.Background()
+
+	err := NewTransactionManager(pool).WithTransaction(ctx, func(txCtx context.Context) error {
+		claimed, err := repo.Claim(txCtx, 2, time.Hour)
+		if err != nil {
+			return err
+		}
+		if len(claimed) != 2 {
+			t.Fatalf("claimed %d messages, want 2", len(claimed))
+		}
+
+		// a second relay skips the rows locked by the first one
+		others, err := repo.Claim(ctx, 10, time.Hour)
+		if err != nil {
+			return err
+		}
+		if len(others) != 1 || others[0].ID == claimed[0].ID || others[0].ID == claimed[1].ID {
+			t.Fatalf("second relay claimed %+v, want only the unlocked message", others)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTransaction: %v", err)
+	}
+
+	leased, err := repo.Claim(ctx, 10, time.Hour)
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	if len(leased) != 0 {
+		t.Fatalf("claimed %d leased messages, want 0", len(leased))
+	}
+}
+
+func TestOutboxClaimRedeliversExpiredLease(t *testing.T) {
+	pool := testPool(t)
+	repo := newTestOutbox(t, pool, 1)
+	ctx := context.Background()
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		claimed, err := repo.Claim(ctx, 10, 0)
+		if err != nil {
+			t.Fatalf("Claim: %v", err)
+		}
+		if len(claimed) != 1 || claimed[0].Attempts != attempt {
+			t.Fatalf("claim %d returned %+v, want the message at attempt %d", attempt, claimed, attempt)
+		}
+	}
+}
+
+func TestOutboxDeleteFinished(t *testing.T) {
+	pool := testPool(t)
+	repo := newTestOutbox(t, pool, 3)
+	ctx := context.Background()
+
+	claimed, err := repo.Claim(ctx, 10, 0)
+	if err != nil || len(claimed) != 3 {
+		t.Fatalf("Claim = %d messages, %v, want 3", len(claimed), err)
+	}
+	if err := repo.MarkDelivered(ctx, claimed[0].ID); err != nil {
+		t.Fatalf("MarkDelivered: %v", err)
+	}
+	if err := repo.MarkDead(ctx, claimed[1].ID, errors.New("boom")); err != nil {
+		t.Fatalf("MarkDead: %v", err)
+	}
+
+	deleted, err := repo.DeleteFinished(ctx, time.Now().Add(-time.Hour), 10)
+	if err != nil || deleted != 0 {
+		t.Fatalf("DeleteFinished before they finished = %d, %v, want 0", deleted, err)
+	}
+
+	deleted, err = repo.DeleteFinished(ctx, time.Now().Add(time.Minute), 10)
+	if err != nil || deleted != 2 {
+		t.Fatalf("DeleteFinished = %d, %v, want 2", deleted, err)
+	}
+
+	// the pending message is still delivered
+	pending, err := repo.Claim(ctx, 10, 0)
+	if err != nil || len(pending) != 1 || pending[0].ID != claimed[2].ID {
+		t.Fatalf("Claim = %+v, %v, want the pending message", pending, err)
+	}
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testDSNEnv names a scratch database for the tests that need Postgres. They
+// migrate it and may delete or insert any data; without it they are skipped.
+//
+//	PRODUCT_TEST_DSN=postgres://... go test ./app/repository/db
+const testDSNEnv = "PRODUCT_TEST_DSN"
+
+// migratedPool applies the migrations to the database dsn names and opens a
+// pool on it, which is closed when tb ends.
+func migratedPool(tb testing.TB, dsn string) *pgxpool.Pool {
+	tb.Helper()
+
+	database, err := sql.Open("pgx", dsn)
+	if err != nil {
+		tb.Fatalf("sql.Open: %v", err)
+	}
+	migrator, err := newMigrator(database, 0)
+	if err != nil {
+		tb.Fatalf("newMigrator: %v", err)
+	}
+	defer migrator.Close()
+	if err := migrator.Up(); err != nil {
+		tb.Fatalf("migrate up: %v", err)
+	}
+
+	pool, err := pgxpool.New(context.Background(), dsn)
+	if err != nil {
+		tb.Fatalf("pgxpool.New: %v", err)
+	}
+	tb.Cleanup(pool.Close)
+	return pool
+}
+
+// testPool is migratedPool on the database of testDSNEnv.
+func testPool(t *testing.T) *pgxpool.Pool {
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDSNEnv)
+	}
+	return migratedPool(t, dsn)
+}
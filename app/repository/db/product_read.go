@@ -2,99 +2,395 @@ package db
 
 import (
 	"context"
-	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"product-service/app/domain"
+	"product-service/config"
 	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const productColumns = `id, name, description, price, category_id, category, image_url, shop_id, attributes, active, version, created_at, updated_at`
+
+const (
+	// searchConfig is the text search configuration search_vector is built
+	// with, see the product search migration.
+	searchConfig    = "simple"
+	headlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2"
+)
+
+// categorySubtreeQuery selects the ids of a category and all its descendants,
+// the root is matched on the column given by the first verb.
+const categorySubtreeQuery = `WITH RECURSIVE subtree AS (
+		SELECT id FROM categories WHERE %s = $%d
+		UNION ALL
+		SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id
+	) SELECT id FROM subtree`
+
+// maxFacetValues caps the number of values returned per category or shop facet.
+const maxFacetValues = 50
+
 type productReadRepository struct {
-	conn *sql.DB
+	router *replicaRouter
+}
+
+// NewProductReadRepository reads from replica when one is given, see
+// replicaRouter. It also implements domain.ProductCacheInvalidator to learn
+// which products were just written.
+func NewProductReadRepository(primary, replica *pgxpool.Pool, cfg config.DbConfig) domain.ProductReadRepository {
+	maxLag := time.Duration(cfg.ReplicaMaxLagMs) * time.Millisecond
+	window := time.Duration(cfg.ReadYourWritesMs) * time.Millisecond
+	return &productReadRepository{newReplicaRouter(primary, replica, maxLag, window)}
 }
 
-func NewProductReadRepository(db *sql.DB) domain.ProductReadRepository {
-	return &productReadRepository{db}
+// Invalidate sends reads of the product to the primary for the
+// read-your-writes window.
+func (r *productReadRepository) Invalidate(ctx context.Context, id int64) error {
+	r.router.markWritten(id)
+	return nil
 }
 
 func (r *productReadRepository) GetByID(ctx context.Context, id int64) (*domain.Product, error) {
-	query := `SELECT id, name, description, price, category, image_url, shop_id, active, created_at, updated_at FROM products WHERE id = $1 AND active = true`
-	row := r.conn.QueryRowContext(ctx, query, id)
+	query := `SELECT ` + productColumns + ` FROM products WHERE id = $1 AND active = true`
+	row := r.router.reader(ctx, id).QueryRow(ctx, query, id)
 
-	var product domain.Product
-	if err := row.Scan(&product.ID, &product.Name, &product.Description, &product.Price, &product.Category, &product.ImageURL, &product.ShopID, &product.Active, &product.CreatedAt, &product.UpdatedAt); err != nil {
-		if err == sql.ErrNoRows {
+	product, err := scanProduct(row)
+	if err != nil {
+		if err == pgx.ErrNoRows {
 			return nil, domain.ErrNotFound
 		}
 		slog.ErrorContext(ctx, "[productReadRepository] GetByID", "query", err)
 		return nil, domain.ErrInternal
 	}
 
-	return &product, nil
+	return product, nil
 }
 
 func (r *productReadRepository) GetListByQuery(ctx context.Context, query domain.ProductQuery) ([]*domain.Product, error) {
-	sqlQuery := `SELECT id, name, description, price, category, image_url, shop_id, active, created_at, updated_at FROM products WHERE active = true`
+	sqlQuery, args := listQuery(query)
+	rows, err := r.router.reader(ctx).Query(ctx, sqlQuery, args...)
+	if err != nil {
+		slog.ErrorContext(ctx, "[productReadRepository] GetListByQuery", "query", err)
+		return nil, domain.ErrInternal
+	}
+	defer rows.Close()
+
+	var products []*domain.Product
+	for rows.Next() {
+		var product *domain.Product
+		if query.Keyword != "" {
+			var highlight domain.SearchHighlight
+			product, err = scanProduct(rows, &highlight.Rank, &highlight.Name, &highlight.Description)
+			if product != nil {
+				product.Highlight = &highlight
+			}
+		} else {
+			product, err = scanProduct(rows)
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "[productReadRepository] GetListByQuery", "scan", err)
+			return nil, domain.ErrInternal
+		}
+		products = append(products, product)
+	}
+	// a page cut short by a failed stream would yield a cursor past unread rows
+	if err := rows.Err(); err != nil {
+		slog.ErrorContext(ctx, "[productReadRepository] GetListByQuery", "rows", err)
+		return nil, domain.ErrInternal
+	}
+
+	return products, nil
+}
+
+// listQuery builds the statement GetListByQuery runs. With a keyword the rank
+// and the name and description headlines follow the product columns.
+func listQuery(query domain.ProductQuery) (string, []any) {
+	conditions, args := productFilters(query, "")
+	placeholderIndex := len(args) + 1
+
+	columns := productColumns
+	// the keyword is always the first argument, see productFilters
+	rankExpr := fmt.Sprintf("ts_rank_cd(search_vector, websearch_to_tsquery('%s', $1))", searchConfig)
+	if query.Keyword != "" {
+		tsQuery := fmt.Sprintf("websearch_to_tsquery('%s', $1)", searchConfig)
+		columns += fmt.Sprintf(", %[1]s AS rank, ts_headline('%[2]s', name, %[3]s, '%[4]s'), ts_headline('%[2]s', description, %[3]s, '%[4]s')",
+			rankExpr, searchConfig, tsQuery, headlineOptions)
+	}
+
+	if query.SortBy == "" || (query.SortBy == "relevance" && query.Keyword == "") {
+		query.SortBy = "created_at"
+	}
+	if query.SortOrder == "" {
+		query.SortOrder = "asc"
+	}
+
+	sortKey := query.SortBy
+	if query.SortBy == "relevance" {
+		sortKey = rankExpr
+	}
+
+	if after := query.After; after != nil {
+		condition, keysetArgs := keysetCondition(query.SortBy, query.SortOrder, sortKey, after, placeholderIndex)
+		conditions += " AND " + condition
+		args = append(args, keysetArgs...)
+		placeholderIndex += len(keysetArgs)
+	}
+
+	sqlQuery := "SELECT " + columns + " FROM products WHERE " + conditions
+	if query.SortBy == "relevance" {
+		sqlQuery += " ORDER BY rank " + query.SortOrder + ", id " + query.SortOrder
+	} else {
+		sqlQuery += " ORDER BY " + query.SortBy + " " + query.SortOrder + ", id " + query.SortOrder
+	}
+
+	if query.Limit > 0 {
+		sqlQuery += fmt.Sprintf(" LIMIT $%d", placeholderIndex)
+		args = append(args, query.Limit)
+	}
+
+	return sqlQuery, args
+}
+
+// keysetCondition selects the rows after the cursor position. The product ID
+// breaks ties between rows with the same sort key, so no row is skipped or
+// repeated across pages.
+func keysetCondition(sortBy, sortOrder, sortKey string, after *domain.ProductCursor, placeholderIndex int) (string, []any) {
+	comparison := "<"
+	if sortOrder == "asc" {
+		comparison = ">"
+	}
+
+	var value any
+	param := fmt.Sprintf("$%d", placeholderIndex)
+	switch sortBy {
+	case "price":
+		value = after.Price
+	case "relevance":
+		value = after.Rank
+		param += "::real"
+	default:
+		value = after.CreatedAt
+	}
+
+	return fmt.Sprintf("(%s, id) %s (%s, $%d)", sortKey, comparison, param, placeholderIndex+1), []any{value, after.ID}
+}
+
+func (r *productReadRepository) CountByQuery(ctx context.Context, query domain.ProductQuery) (int64, error) {
+	conditions, args := productFilters(query, "")
+
+	var total int64
+	if err := r.router.reader(ctx).QueryRow(ctx, "SELECT COUNT(*) FROM products WHERE "+conditions, args...).Scan(&total); err != nil {
+		slog.ErrorContext(ctx, "[productReadRepository] CountByQuery", "scan", err)
+		return 0, domain.ErrInternal
+	}
+
+	return total, nil
+}
+
+// GetFacets counts the products matching query per requested facet. Each
+// facet ignores its own filter so the storefront can offer the alternatives.
+func (r *productReadRepository) GetFacets(ctx context.Context, query domain.ProductQuery, facets []string) (*domain.ProductFacets, error) {
+	res := &domain.ProductFacets{}
+	for _, facet := range facets {
+		var err error
+		switch facet {
+		case domain.FacetCategory:
+			res.Category, err = r.categoryFacet(ctx, query)
+		case domain.FacetPrice:
+			res.Price, err = r.priceFacet(ctx, query)
+		case domain.FacetShop:
+			res.Shop, err = r.shopFacet(ctx, query)
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "[productReadRepository] GetFacets", "facet", facet, "error", err)
+			return nil, domain.ErrInternal
+		}
+	}
+
+	return res, nil
+}
+
+// categoryFacetQuery counts the matching products per category, then adds
+// every count to all ancestors of its category, the same tree the category
+// filter walks down.
+const categoryFacetQuery = `WITH RECURSIVE counts AS (
+		SELECT category_id, COUNT(*) AS products FROM products WHERE %s GROUP BY category_id
+	), ancestors AS (
+		SELECT category_id, category_id AS ancestor_id FROM counts
+		UNION ALL
+		SELECT a.category_id, c.parent_id FROM ancestors a JOIN categories c ON c.id = a.ancestor_id
+		WHERE c.parent_id IS NOT NULL
+	)
+	SELECT c.id, c.parent_id, c.slug, SUM(counts.products)::bigint AS total
+	FROM ancestors a
+	JOIN counts ON counts.category_id = a.category_id
+	JOIN categories c ON c.id = a.ancestor_id
+	GROUP BY c.id, c.parent_id, c.slug
+	ORDER BY total DESC, c.slug LIMIT %d`
+
+func (r *productReadRepository) categoryFacet(ctx context.Context, query domain.ProductQuery) ([]*domain.CategoryFacet, error) {
+	conditions, args := productFilters(query, domain.FacetCategory)
+	sqlQuery := fmt.Sprintf(categoryFacetQuery, conditions, maxFacetValues)
+
+	rows, err := r.router.reader(ctx).Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []*domain.CategoryFacet{}
+	for rows.Next() {
+		var facet domain.CategoryFacet
+		if err := rows.Scan(&facet.CategoryID, &facet.ParentID, &facet.Category, &facet.Count); err != nil {
+			return nil, err
+		}
+		res = append(res, &facet)
+	}
+	return res, rows.Err()
+}
+
+func (r *productReadRepository) shopFacet(ctx context.Context, query domain.ProductQuery) ([]*domain.ShopFacet, error) {
+	conditions, args := productFilters(query, domain.FacetShop)
+	sqlQuery := fmt.Sprintf(`SELECT shop_id, COUNT(*) FROM products WHERE %s
+		GROUP BY shop_id ORDER BY COUNT(*) DESC, shop_id LIMIT %d`, conditions, maxFacetValues)
+
+	rows, err := r.router.reader(ctx).Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []*domain.ShopFacet{}
+	for rows.Next() {
+		var facet domain.ShopFacet
+		if err := rows.Scan(&facet.ShopID, &facet.Count); err != nil {
+			return nil, err
+		}
+		res = append(res, &facet)
+	}
+	return res, rows.Err()
+}
+
+func (r *productReadRepository) priceFacet(ctx context.Context, query domain.ProductQuery) ([]*domain.PriceFacet, error) {
+	conditions, args := productFilters(query, domain.FacetPrice)
+	sqlQuery := fmt.Sprintf(`SELECT width_bucket(price, $%d::bigint[]) AS bucket, COUNT(*) FROM products WHERE %s
+		GROUP BY bucket ORDER BY bucket`, len(args)+1, conditions)
+	args = append(args, domain.PriceFacetBounds)
+
+	rows, err := r.router.reader(ctx).Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[int]int64{}
+	for rows.Next() {
+		var bucket int
+		var count int64
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, err
+		}
+		counts[bucket] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// width_bucket returns i when bounds[i-1] <= price < bounds[i]
+	res := make([]*domain.PriceFacet, 0, len(domain.PriceFacetBounds)+1)
+	for i := 0; i <= len(domain.PriceFacetBounds); i++ {
+		facet := &domain.PriceFacet{Count: counts[i]}
+		if i > 0 {
+			facet.Min = &domain.PriceFacetBounds[i-1]
+		}
+		if i < len(domain.PriceFacetBounds) {
+			facet.Max = &domain.PriceFacetBounds[i]
+		}
+		res = append(res, facet)
+	}
+	return res, nil
+}
+
+// productFilters turns the query filters into a WHERE clause and its
+// arguments, numbering placeholders from $1. The keyword, when present, is
+// always $1. skipFacet leaves out the filter belonging to that facet.
+func productFilters(query domain.ProductQuery, skipFacet string) (string, []any) {
+	conditions := "active = true"
 	args := []any{}
 
 	placeholderIndex := 1 // Start placeholder index
 
-	if query.ShopID > 0 {
-		sqlQuery += fmt.Sprintf(" AND shop_id = $%d", placeholderIndex)
+	if query.Keyword != "" {
+		conditions += fmt.Sprintf(" AND search_vector @@ websearch_to_tsquery('%s', $%d)", searchConfig, placeholderIndex)
+		args = append(args, query.Keyword)
+		placeholderIndex++
+	}
+	if query.ShopID > 0 && skipFacet != domain.FacetShop {
+		conditions += fmt.Sprintf(" AND shop_id = $%d", placeholderIndex)
 		args = append(args, query.ShopID)
 		placeholderIndex++
 	}
-	if query.Category != "" {
-		sqlQuery += fmt.Sprintf(" AND category = $%d", placeholderIndex)
+	if query.CategoryID > 0 && skipFacet != domain.FacetCategory {
+		conditions += fmt.Sprintf(" AND category_id IN ("+categorySubtreeQuery+")", "id", placeholderIndex)
+		args = append(args, query.CategoryID)
+		placeholderIndex++
+	}
+	if query.Category != "" && skipFacet != domain.FacetCategory {
+		conditions += fmt.Sprintf(" AND category_id IN ("+categorySubtreeQuery+")", "slug", placeholderIndex)
 		args = append(args, strings.ToLower(query.Category))
 		placeholderIndex++
 	}
-	if query.MinPrice > 0 {
-		sqlQuery += fmt.Sprintf(" AND price >= $%d", placeholderIndex)
+	if query.MinPrice > 0 && skipFacet != domain.FacetPrice {
+		conditions += fmt.Sprintf(" AND price >= $%d", placeholderIndex)
 		args = append(args, query.MinPrice)
 		placeholderIndex++
 	}
-	if query.MaxPrice > 0 {
-		sqlQuery += fmt.Sprintf(" AND price <= $%d", placeholderIndex)
+	if query.MaxPrice > 0 && skipFacet != domain.FacetPrice {
+		conditions += fmt.Sprintf(" AND price <= $%d", placeholderIndex)
 		args = append(args, query.MaxPrice)
 		placeholderIndex++
 	}
-	if query.Keyword != "" {
-		sqlQuery += fmt.Sprintf(" AND (name ILIKE $%d OR description ILIKE $%d)", placeholderIndex, placeholderIndex)
-		args = append(args, "%"+query.Keyword+"%")
-		placeholderIndex++
-	}
-
-	if query.SortBy == "" {
-		query.SortBy = "created_at"
-	}
-	if query.SortOrder == "" {
-		query.SortOrder = "asc"
+	for _, filter := range query.Attributes {
+		switch filter.Op {
+		case domain.AttributeFilterEq, domain.AttributeFilterNe:
+			conditions += fmt.Sprintf(" AND attributes->>$%d::text %s $%d", placeholderIndex, sqlOperator(filter.Op), placeholderIndex+1)
+		case domain.AttributeFilterGt, domain.AttributeFilterGte, domain.AttributeFilterLt, domain.AttributeFilterLte:
+			// only numeric attributes take part in range comparisons
+			conditions += fmt.Sprintf(" AND (CASE WHEN jsonb_typeof(attributes->$%d::text) = 'number' THEN (attributes->>$%d::text)::numeric END) %s $%d::numeric",
+				placeholderIndex, placeholderIndex, sqlOperator(filter.Op), placeholderIndex+1)
+		default:
+			continue
+		}
+		args = append(args, filter.Code, filter.Value)
+		placeholderIndex += 2
 	}
 
-	sqlQuery += " ORDER BY " + query.SortBy + " " + query.SortOrder
+	return conditions, args
+}
 
-	if query.Limit > 0 {
-		sqlQuery += fmt.Sprintf(" LIMIT $%d OFFSET $%d", placeholderIndex, placeholderIndex+1)
-		args = append(args, query.Limit, (query.Page-1)*query.Limit)
+func sqlOperator(op domain.AttributeFilterOp) string {
+	if op == domain.AttributeFilterNe {
+		return "<>"
 	}
+	return string(op)
+}
 
-	rows, err := r.conn.QueryContext(ctx, sqlQuery, args...)
-	if err != nil {
-		slog.ErrorContext(ctx, "[productReadRepository] GetListByQuery", "query", err)
-		return nil, domain.ErrInternal
+// scanProduct scans productColumns followed by any extra selected columns.
+func scanProduct(row rowScanner, extra ...any) (*domain.Product, error) {
+	var product domain.Product
+	var attributes []byte
+	dest := append([]any{&product.ID, &product.Name, &product.Description, &product.Price, &product.CategoryID, &product.Category, &product.ImageURL, &product.ShopID, &attributes, &product.Active, &product.Version, &product.CreatedAt, &product.UpdatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
 	}
-	defer rows.Close()
 
-	var products []*domain.Product
-	for rows.Next() {
-		var product domain.Product
-		if err := rows.Scan(&product.ID, &product.Name, &product.Description, &product.Price, &product.Category, &product.ImageURL, &product.ShopID, &product.Active, &product.CreatedAt, &product.UpdatedAt); err != nil {
-			slog.ErrorContext(ctx, "[productReadRepository] GetListByQuery", "scan", err)
-			return nil, domain.ErrInternal
-		}
-		products = append(products, &product)
+	if err := json.Unmarshal(attributes, &product.Attributes); err != nil {
+		return nil, err
 	}
 
-	return products, nil
+	return &product, nil
 }
@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"product-service/app/domain"
+	"product-service/config"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// benchDSNEnv names the database the GetListByQuery benchmarks run against.
+// The benchmarks migrate it and top it up to benchProducts products, so an
+// empty database is enough; they are skipped without it. Run them with
+//
+//	PRODUCT_BENCH_DSN=postgres://... make bench
+const benchDSNEnv = "PRODUCT_BENCH_DSN"
+
+// benchProducts is the number of products the benchmarks query. Every tenth
+// one matches the keyword query.
+const benchProducts = 100_000
+
+var benchListQueries = []struct {
+	name  string
+	query domain.ProductQuery
+}{
+	{name: "latest", query: domain.ProductQuery{SortBy: "created_at", SortOrder: "desc", Limit: 20}},
+	{name: "price range", query: domain.ProductQuery{MinPrice: 1000, MaxPrice: 500000, SortBy: "price", Limit: 20}},
+	{name: "keyword", query: domain.ProductQuery{Keyword: "lamp", SortBy: "relevance", SortOrder: "desc", Limit: 20}},
+}
+
+// BenchmarkGetListByQuery compares the pgxpool repository with the
+// database/sql handle it replaced, configured like the old NewPostgres, on
+// the same statements.
+func BenchmarkGetListByQuery(b *testing.B) {
+	dsn := os.Getenv(benchDSNEnv)
+	if dsn == "" {
+		b.Skipf("%s is not set", benchDSNEnv)
+	}
+	ctx := context.Background()
+
+	pool := migratedPool(b, dsn)
+	seedBenchProducts(b, pool)
+	repo := NewProductReadRepository(pool, nil, config.DbConfig{})
+
+	sqlDB, err := sql.Open("pgx", dsn)
+	if err != nil {
+		b.Fatalf("sql.Open: %v", err)
+	}
+	defer sqlDB.Close()
+	sqlDB.SetMaxOpenConns(10)
+	sqlDB.SetMaxIdleConns(5)
+	sqlDB.SetConnMaxLifetime(30 * time.Minute)
+
+	for _, bq := range benchListQueries {
+		b.Run(bq.name+"/pgxpool", func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := repo.GetListByQuery(ctx, bq.query); err != nil {
+						b.Fatalf("GetListByQuery: %v", err)
+					}
+				}
+			})
+		})
+
+		b.Run(bq.name+"/database_sql", func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := sqlListByQuery(ctx, sqlDB, bq.query); err != nil {
+						b.Fatalf("sqlListByQuery: %v", err)
+					}
+				}
+			})
+		})
+	}
+}
+
+// seedBenchProducts inserts products until the database holds benchProducts
+// of them, all in one bench category.
+func seedBenchProducts(b *testing.B, pool *pgxpool.Pool) {
+	b.Helper()
+	ctx := context.Background()
+
+	var existing int
+	if err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM products`).Scan(&existing); err != nil {
+		b.Fatalf("count products: %v", err)
+	}
+	if existing >= benchProducts {
+		return
+	}
+
+	query := `INSERT INTO categories (name, slug) VALUES ('Bench', 'bench') ON CONFLICT (slug) DO NOTHING`
+	if _, err := pool.Exec(ctx, query); err != nil {
+		b.Fatalf("seed category: %v", err)
+	}
+	query = `INSERT INTO products (name, description, price, category_id, category, image_url, shop_id)
+		SELECT 'Product ' || i || CASE WHEN i % 10 = 0 THEN ' lamp' ELSE '' END,
+			'Description of bench product ' || i,
+			100 + (i * 7919) % 1000000,
+			c.id, c.slug,
+			'https://cdn.example.com/bench/' || i || '.jpg',
+			1 + i % 50
+		FROM generate_series($1::bigint + 1, $2::bigint) AS i, categories c
+		WHERE c.slug = 'bench'`
+	if _, err := pool.Exec(ctx, query, existing, benchProducts); err != nil {
+		b.Fatalf("seed products: %v", err)
+	}
+	if _, err := pool.Exec(ctx, `ANALYZE products`); err != nil {
+		b.Fatalf("analyze products: %v", err)
+	}
+}
+
+// sqlListByQuery is GetListByQuery on a database/sql handle.
+func sqlListByQuery(ctx context.Context, db *sql.DB, query domain.ProductQuery) ([]*domain.Product, error) {
+	sqlQuery, args := listQuery(query)
+	rows, err := db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []*domain.Product
+	for rows.Next() {
+		var product *domain.Product
+		if query.Keyword != "" {
+			var highlight domain.SearchHighlight
+			product, err = scanProduct(rows, &highlight.Rank, &highlight.Name, &highlight.Description)
+			if product != nil {
+				product.Highlight = &highlight
+			}
+		} else {
+			product, err = scanProduct(rows)
+		}
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+
+	return products, rows.Err()
+}
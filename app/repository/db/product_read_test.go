@@ -0,0 +1,44 @@
+package db
+
+import (
+	"product-service/app/domain"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestKeysetCondition(t *testing.T) {
+	createdAt := time.Date(2025, 3, 1, 10, 30, 0, 0, time.UTC)
+	price := int64(150000)
+	rank := 0.25
+	after := &domain.ProductCursor{CreatedAt: &createdAt, Price: &price, Rank: &rank, ID: 7}
+
+	tests := []struct {
+		name      string
+		sortBy    string
+		sortOrder string
+		sortKey   string
+		want      string
+		wantValue any
+	}{
+		{name: "created_at asc", sortBy: "created_at", sortOrder: "asc", sortKey: "created_at", want: "(created_at, id) > ($3, $4)", wantValue: &createdAt},
+		{name: "created_at desc", sortBy: "created_at", sortOrder: "desc", sortKey: "created_at", want: "(created_at, id) < ($3, $4)", wantValue: &createdAt},
+		{name: "price asc", sortBy: "price", sortOrder: "asc", sortKey: "price", want: "(price, id) > ($3, $4)", wantValue: &price},
+		{name: "price desc", sortBy: "price", sortOrder: "desc", sortKey: "price", want: "(price, id) < ($3, $4)", wantValue: &price},
+		{name: "relevance", sortBy: "relevance", sortOrder: "desc", sortKey: "rank_expr", want: "(rank_expr, id) < ($3::real, $4)", wantValue: &rank},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, args := keysetCondition(tt.sortBy, tt.sortOrder, tt.sortKey, after, 3)
+			if got != tt.want {
+				t.Fatalf("condition = %q, want %q", got, tt.want)
+			}
+			// The ID is always compared after the sort key, so products
+			// sharing a sort key are split between pages by ID.
+			if want := []any{tt.wantValue, int64(7)}; !reflect.DeepEqual(args, want) {
+				t.Fatalf("args = %v, want %v", args, want)
+			}
+		})
+	}
+}
@@ -2,32 +2,60 @@ package db
 
 import (
 	"context"
-	"database/sql"
+	"encoding/json"
+	"errors"
 	"log/slog"
 	"product-service/app/domain"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type productWriteRepository struct {
-	conn *sql.DB
+	conn *pgxpool.Pool
 }
 
-func NewProductWriteRepository(db *sql.DB) domain.ProductWriteRepository {
+func NewProductWriteRepository(db *pgxpool.Pool) domain.ProductWriteRepository {
 	return &productWriteRepository{db}
 }
 
+func (r *productWriteRepository) GetByID(ctx context.Context, id int64) (*domain.Product, error) {
+	query := `SELECT ` + productColumns + ` FROM products WHERE id = $1`
+	row := conn(ctx, r.conn).QueryRow(ctx, query, id)
+
+	product, err := scanProduct(row)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, domain.ErrNotFound
+		}
+		slog.ErrorContext(ctx, "[productWriteRepository] GetByID", "query", err)
+		return nil, domain.ErrInternal
+	}
+
+	return product, nil
+}
+
 func (r *productWriteRepository) Create(ctx context.Context, product *domain.Product) error {
-	query := `INSERT INTO products (name, description, price, category, image_url, shop_id, active) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id , created_at, updated_at`
+	attributes, err := marshalAttributes(product.Attributes)
+	if err != nil {
+		slog.ErrorContext(ctx, "[productWriteRepository] Create", "marshalAttributes", err)
+		return domain.ErrInternal
+	}
+
+	query := `INSERT INTO products (name, description, price, category_id, category, image_url, shop_id, attributes, active) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, version, created_at, updated_at`
 
-	err := r.conn.QueryRowContext(ctx, query,
+	err = conn(ctx, r.conn).QueryRow(ctx, query,
 		product.Name,
 		product.Description,
 		product.Price,
+		product.CategoryID,
 		product.Category,
 		product.ImageURL,
 		product.ShopID,
+		attributes,
 		product.Active).
-		Scan(&product.ID, &product.CreatedAt, &product.UpdatedAt)
+		Scan(&product.ID, &product.Version, &product.CreatedAt, &product.UpdatedAt)
 	if err != nil {
 		slog.ErrorContext(ctx, "[productWriteRepository] Create", "scan", err)
 		return domain.ErrInternal
@@ -36,50 +64,84 @@ func (r *productWriteRepository) Create(ctx context.Context, product *domain.Pro
 	return nil
 }
 
+// Update writes the product only if its stored version still equals
+// product.Version, then bumps the version. A lost race yields ErrPreconditionFailed.
 func (r *productWriteRepository) Update(ctx context.Context, product *domain.Product) error {
-	query := `UPDATE products SET name = $1, description = $2, price = $3, category = $4, image_url = $5, shop_id = $6, active = $7, updated_at = $8 WHERE id = $9`
-	_, err := r.conn.ExecContext(ctx, query,
+	attributes, err := marshalAttributes(product.Attributes)
+	if err != nil {
+		slog.ErrorContext(ctx, "[productWriteRepository] Update", "marshalAttributes", err)
+		return domain.ErrInternal
+	}
+
+	query := `UPDATE products SET name = $1, description = $2, price = $3, category_id = $4, category = $5, image_url = $6, attributes = $7, active = $8, updated_at = NOW(), version = version + 1
+		WHERE id = $9 AND version = $10 RETURNING version, updated_at`
+	err = conn(ctx, r.conn).QueryRow(ctx, query,
 		product.Name,
 		product.Description,
 		product.Price,
+		product.CategoryID,
 		product.Category,
 		product.ImageURL,
-		product.ShopID,
+		attributes,
 		product.Active,
-		product.UpdatedAt,
-		product.ID)
+		product.ID,
+		product.Version).
+		Scan(&product.Version, &product.UpdatedAt)
 	if err != nil {
-		slog.ErrorContext(ctx, "[productWriteRepository] Update", "exec", err)
+		if err == pgx.ErrNoRows {
+			return domain.ErrPreconditionFailed
+		}
+		slog.ErrorContext(ctx, "[productWriteRepository] Update", "scan", err)
 		return domain.ErrInternal
 	}
 
 	return nil
 }
 
-func (r *productWriteRepository) SetActiveStatus(ctx context.Context, id int64, active bool) error {
-	query := `UPDATE products SET active = $1 WHERE id = $2`
-	_, err := r.conn.ExecContext(ctx, query, active, id)
+func (r *productWriteRepository) SetActiveStatus(ctx context.Context, id int64, version int64, active bool) (int64, error) {
+	query := `UPDATE products SET active = $1, updated_at = NOW(), version = version + 1 WHERE id = $2 AND version = $3 RETURNING version`
+	var newVersion int64
+	err := conn(ctx, r.conn).QueryRow(ctx, query, active, id, version).Scan(&newVersion)
 	if err != nil {
-		slog.ErrorContext(ctx, "[productWriteRepository] SetActiveStatus", "exec", err)
-		return domain.ErrInternal
+		if err == pgx.ErrNoRows {
+			return 0, domain.ErrPreconditionFailed
+		}
+		slog.ErrorContext(ctx, "[productWriteRepository] SetActiveStatus", "scan", err)
+		return 0, domain.ErrInternal
 	}
 
-	return nil
+	return newVersion, nil
 }
 
-func (r *productWriteRepository) WithTransaction(ctx context.Context, fn func(context.Context, *sql.Tx) error) error {
-	tx, err := r.conn.BeginTx(ctx, nil)
+func (r *productWriteRepository) BumpVersion(ctx context.Context, id int64) (int64, error) {
+	query := `UPDATE products SET updated_at = NOW(), version = version + 1 WHERE id = $1 RETURNING version`
+	var newVersion int64
+	err := conn(ctx, r.conn).QueryRow(ctx, query, id).Scan(&newVersion)
 	if err != nil {
-		slog.ErrorContext(ctx, "[productWriteRepository] BeginTransaction", "beginTx", err)
-		return err
+		if err == pgx.ErrNoRows {
+			return 0, domain.ErrNotFound
+		}
+		slog.ErrorContext(ctx, "[productWriteRepository] BumpVersion", "scan", err)
+		return 0, domain.ErrInternal
 	}
 
-	if err := fn(ctx, tx); err != nil {
-		if rollbackErr := tx.Rollback(); rollbackErr != nil {
-			slog.ErrorContext(ctx, "[productWriteRepository] WithTransaction", "rollback", rollbackErr)
-			return rollbackErr
+	return newVersion, nil
+}
+
+func (r *productWriteRepository) Lock(ctx context.Context, id int64) error {
+	if err := lockProduct(ctx, conn(ctx, r.conn), id); err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return err
 		}
-		return err
+		slog.ErrorContext(ctx, "[productWriteRepository] Lock", "error", err)
+		return domain.ErrInternal
+	}
+	return nil
+}
+
+func marshalAttributes(attributes map[string]any) ([]byte, error) {
+	if attributes == nil {
+		return []byte("{}"), nil
 	}
-	return tx.Commit()
+	return json.Marshal(attributes)
 }
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"log/slog"
+	"product-service/pkg/ctxutil"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	defaultReplicaMaxLag        = time.Second
+	defaultReadYourWritesWindow = 5 * time.Second
+	replicaCheckInterval        = time.Second
+	replicaCheckTimeout         = 500 * time.Millisecond
+)
+
+// replicaLagQuery reports how far the replica is behind in seconds. A replica
+// that replayed everything it received counts as caught up, however old its
+// last transaction is.
+const replicaLagQuery = `SELECT COALESCE(CASE
+		WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE EXTRACT(EPOCH FROM NOW() - pg_last_xact_replay_timestamp())
+	END, 0)`
+
+// replicaRouter picks the pool a read runs on. Reads go to the replica unless
+// it lags more than maxLag or cannot be reached, they touch a product
+// written within the read-your-writes window, or ctx asks for the primary.
+// Written products are tracked in memory, so the window only covers reads
+// served by the instance that wrote; the product cache marks writes in Redis
+// and asks for the primary on behalf of the other instances.
+type replicaRouter struct {
+	primary *pgxpool.Pool
+	replica *pgxpool.Pool
+	maxLag  time.Duration
+	window  time.Duration
+
+	healthy  atomic.Bool
+	checking sync.Mutex
+	// checkedAt holds the unix nanoseconds of the last replica check.
+	checkedAt atomic.Int64
+
+	mu       sync.Mutex
+	written  map[int64]time.Time
+	prunedAt time.Time
+}
+
+func newReplicaRouter(primary, replica *pgxpool.Pool, maxLag, window time.Duration) *replicaRouter {
+	if maxLag <= 0 {
+		maxLag = defaultReplicaMaxLag
+	}
+	if window <= 0 {
+		window = defaultReadYourWritesWindow
+	}
+	return &replicaRouter{
+		primary: primary,
+		replica: replica,
+		maxLag:  maxLag,
+		window:  window,
+		written: make(map[int64]time.Time),
+	}
+}
+
+// reader returns the pool to read productIDs from, or the transaction
+// carried by ctx. Pass no ids for queries that are not about given products.
+func (r *replicaRouter) reader(ctx context.Context, productIDs ...int64) executor {
+	if r.replica == nil || inTransaction(ctx) || ctxutil.IsPrimaryRead(ctx) || r.recentlyWritten(productIDs) || !r.replicaHealthy(ctx) {
+		return conn(ctx, r.primary)
+	}
+	return r.replica
+}
+
+// markWritten routes reads of the product to the primary for the
+// read-your-writes window.
+func (r *replicaRouter) markWritten(productID int64) {
+	if r.replica == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.written[productID] = now
+	if now.Sub(r.prunedAt) < r.window {
+		return
+	}
+	for id, writtenAt := range r.written {
+		if now.Sub(writtenAt) >= r.window {
+			delete(r.written, id)
+		}
+	}
+	r.prunedAt = now
+}
+
+func (r *replicaRouter) recentlyWritten(productIDs []int64) bool {
+	if len(productIDs) == 0 {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range productIDs {
+		if writtenAt, ok := r.written[id]; ok && time.Since(writtenAt) < r.window {
+			return true
+		}
+	}
+	return false
+}
+
+// replicaHealthy checks the replica at most once per replicaCheckInterval.
+// Concurrent readers use the previous result instead of waiting for a check.
+func (r *replicaRouter) replicaHealthy(ctx context.Context) bool {
+	if time.Since(time.Unix(0, r.checkedAt.Load())) < replicaCheckInterval || !r.checking.TryLock() {
+		return r.healthy.Load()
+	}
+	defer r.checking.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), replicaCheckTimeout)
+	defer cancel()
+
+	var lagSeconds float64
+	healthy := true
+	if err := r.replica.QueryRow(ctx, replicaLagQuery).Scan(&lagSeconds); err != nil {
+		slog.WarnContext(ctx, "[replicaRouter] replica unreachable, reading from primary", "error", err)
+		healthy = false
+	} else if lag := time.Duration(lagSeconds * float64(time.Second)); lag > r.maxLag {
+		slog.WarnContext(ctx, "[replicaRouter] replica lagging, reading from primary", "lag", lag, "maxLag", r.maxLag)
+		healthy = false
+	}
+
+	if healthy && !r.healthy.Load() {
+		slog.InfoContext(ctx, "[replicaRouter] replica caught up, reading from replica")
+	}
+	r.healthy.Store(healthy)
+	r.checkedAt.Store(time.Now().UnixNano())
+	return healthy
+}
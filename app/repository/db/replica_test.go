@@ -0,0 +1,49 @@
+package db
+
+import (
+	"context"
+	"product-service/pkg/ctxutil"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestReplicaRouterReader(t *testing.T) {
+	ctx := context.Background()
+	// Pools connect lazily, so nothing is dialed here.
+	primary, err := pgxpool.New(ctx, "postgres://primary.invalid/products")
+	if err != nil {
+		t.Fatalf("pgxpool.New: %v", err)
+	}
+	defer primary.Close()
+	replica, err := pgxpool.New(ctx, "postgres://replica.invalid/products")
+	if err != nil {
+		t.Fatalf("pgxpool.New: %v", err)
+	}
+	defer replica.Close()
+
+	router := newReplicaRouter(primary, replica, time.Second, time.Minute)
+	router.healthy.Store(true)
+	router.checkedAt.Store(time.Now().UnixNano())
+	router.markWritten(2)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		ids  []int64
+		want *pgxpool.Pool
+	}{
+		{name: "replica", ctx: ctx, ids: []int64{1}, want: replica},
+		{name: "written here", ctx: ctx, ids: []int64{2}, want: primary},
+		{name: "primary requested", ctx: ctxutil.WithPrimaryRead(ctx), ids: []int64{1}, want: primary},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := router.reader(tt.ctx, tt.ids...); got != tt.want {
+				t.Fatalf("reader returned the wrong pool")
+			}
+		})
+	}
+}
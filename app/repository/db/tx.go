@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"product-service/app/domain"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var errIsolationMismatch = errors.New("nested transaction cannot change the isolation level")
+
+type txKey struct{}
+
+// txState is the transaction carried by a context. Nested transactions are
+// pgx savepoints of the outermost one.
+type txState struct {
+	tx        pgx.Tx
+	isolation sql.IsolationLevel
+}
+
+// executor is what *pgxpool.Pool and pgx.Tx have in common.
+type executor interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// conn returns the transaction carried by ctx, or db when there is none.
+func conn(ctx context.Context, db *pgxpool.Pool) executor {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return state.tx
+	}
+	return db
+}
+
+func inTransaction(ctx context.Context) bool {
+	_, ok := ctx.Value(txKey{}).(*txState)
+	return ok
+}
+
+// isoLevels maps the database/sql isolation levels the domain speaks in onto
+// the ones of pgx.
+var isoLevels = map[sql.IsolationLevel]pgx.TxIsoLevel{
+	sql.LevelDefault:         "",
+	sql.LevelReadUncommitted: pgx.ReadUncommitted,
+	sql.LevelReadCommitted:   pgx.ReadCommitted,
+	sql.LevelRepeatableRead:  pgx.RepeatableRead,
+	sql.LevelSerializable:    pgx.Serializable,
+}
+
+type transactionManager struct {
+	conn *pgxpool.Pool
+}
+
+func NewTransactionManager(db *pgxpool.Pool) domain.TransactionManager {
+	return &transactionManager{db}
+}
+
+func (m *transactionManager) WithTransaction(ctx context.Context, fn func(ctx context.Context) error, opts ...domain.TxOption) error {
+	var options domain.TxOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		if options.Isolation != sql.LevelDefault && options.Isolation != state.isolation {
+			return errIsolationMismatch
+		}
+		// Begin on a transaction creates a savepoint.
+		return run(ctx, state.isolation, fn, func() (pgx.Tx, error) { return state.tx.Begin(ctx) })
+	}
+
+	isoLevel, ok := isoLevels[options.Isolation]
+	if !ok {
+		return fmt.Errorf("unsupported isolation level %s", options.Isolation)
+	}
+	accessMode := pgx.ReadWrite
+	if options.ReadOnly {
+		accessMode = pgx.ReadOnly
+	}
+
+	return run(ctx, options.Isolation, fn, func() (pgx.Tx, error) {
+		return m.conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: isoLevel, AccessMode: accessMode})
+	})
+}
+
+func run(ctx context.Context, isolation sql.IsolationLevel, fn func(ctx context.Context) error, begin func() (pgx.Tx, error)) error {
+	tx, err := begin()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback(context.WithoutCancel(ctx))
+			panic(p)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, txKey{}, &txState{tx: tx, isolation: isolation})); err != nil {
+		if rollbackErr := tx.Rollback(context.WithoutCancel(ctx)); rollbackErr != nil {
+			slog.ErrorContext(ctx, "[transactionManager] WithTransaction", "rollback", rollbackErr)
+		}
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
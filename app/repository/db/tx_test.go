@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"product-service/app/domain"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// fakeTx records how a transaction, or the savepoints begun on it, ended.
+type fakeTx struct {
+	pgx.Tx
+	savepoints []*fakeTx
+	committed  bool
+	rolledBack bool
+}
+
+func (tx *fakeTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	savepoint := &fakeTx{}
+	tx.savepoints = append(tx.savepoints, savepoint)
+	return savepoint, nil
+}
+
+func (tx *fakeTx) Commit(ctx context.Context) error {
+	tx.committed = true
+	return nil
+}
+
+func (tx *fakeTx) Rollback(ctx context.Context) error {
+	tx.rolledBack = true
+	return nil
+}
+
+func TestNestedTransactionUsesSavepoint(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name    string
+		fnErr   error
+		wantErr error
+	}{
+		{name: "commit releases the savepoint"},
+		{name: "error rolls back to the savepoint", fnErr: errFailed, wantErr: errFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outer := &fakeTx{}
+			ctx := context.WithValue(context.Background(), txKey{}, &txState{tx: outer, isolation: sql.LevelSerializable})
+			m := &transactionManager{}
+
+			var inner executor
+			err := m.WithTransaction(ctx, func(ctx context.Context) error {
+				inner = conn(ctx, nil)
+				return tt.fnErr
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			if len(outer.savepoints) != 1 || inner != outer.savepoints[0] {
+				t.Fatalf("fn did not run on a savepoint of the outer transaction")
+			}
+			savepoint := outer.savepoints[0]
+			if savepoint.committed != (tt.fnErr == nil) || savepoint.rolledBack != (tt.fnErr != nil) {
+				t.Fatalf("savepoint committed = %v, rolled back = %v", savepoint.committed, savepoint.rolledBack)
+			}
+			if outer.committed || outer.rolledBack {
+				t.Fatal("the outer transaction was ended by the nested one")
+			}
+		})
+	}
+}
+
+func TestNestedTransactionIsolation(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []domain.TxOption
+		wantErr error
+	}{
+		{name: "inherits the isolation"},
+		{name: "same isolation", opts: []domain.TxOption{domain.WithIsolation(sql.LevelSerializable)}},
+		{name: "other isolation", opts: []domain.TxOption{domain.WithIsolation(sql.LevelReadCommitted)}, wantErr: errIsolationMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outer := &fakeTx{}
+			ctx := context.WithValue(context.Background(), txKey{}, &txState{tx: outer, isolation: sql.LevelSerializable})
+			m := &transactionManager{}
+
+			ran := false
+			err := m.WithTransaction(ctx, func(ctx context.Context) error {
+				ran = true
+				return nil
+			}, tt.opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if ran != (tt.wantErr == nil) {
+				t.Fatalf("fn ran = %v, want %v", ran, tt.wantErr == nil)
+			}
+			if tt.wantErr != nil && len(outer.savepoints) != 0 {
+				t.Fatal("a savepoint was begun despite the isolation mismatch")
+			}
+		})
+	}
+}
+
+// TestNestedTransactionRollbackKeepsOuterWrites checks the savepoint against
+// Postgres: a failed nested transaction undoes only its own writes.
+func TestNestedTransactionRollbackKeepsOuterWrites(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	m := NewTransactionManager(pool)
+
+	err := m.WithTransaction(ctx, func(ctx context.Context) error {
+		if _, err := conn(ctx, pool).Exec(ctx, `CREATE TEMP TABLE tx_test (name TEXT) ON COMMIT DROP`); err != nil {
+			return err
+		}
+		if _, err := conn(ctx, pool).Exec(ctx, `INSERT INTO tx_test VALUES ('outer')`); err != nil {
+			return err
+		}
+
+		nestedErr := m.WithTransaction(ctx, func(ctx context.Context) error {
+			if _, err := conn(ctx, pool).Exec(ctx, `INSERT INTO tx_test VALUES ('inner')`); err != nil {
+				return err
+			}
+			return errors.New("undo inner")
+		})
+		if nestedErr == nil {
+			t.Fatal("nested transaction did not fail")
+		}
+
+		rows, err := conn(ctx, pool).Query(ctx, `SELECT name FROM tx_test`)
+		if err != nil {
+			return err
+		}
+		names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return err
+		}
+		if len(names) != 1 || names[0] != "outer" {
+			t.Fatalf("rows = %v, want only the outer write", names)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTransaction: %v", err)
+	}
+}
@@ -0,0 +1,190 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"product-service/app/domain"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const pgUniqueViolation = "23505"
+
+type variantRepository struct {
+	conn *pgxpool.Pool
+}
+
+func NewVariantRepository(db *pgxpool.Pool) domain.VariantRepository {
+	return &variantRepository{db}
+}
+
+func (r *variantRepository) GetByID(ctx context.Context, productID, id int64) (*domain.ProductVariant, error) {
+	query := `SELECT id, product_id, sku, options, price, image_url, created_at, updated_at FROM product_variants WHERE id = $1 AND product_id = $2`
+	row := conn(ctx, r.conn).QueryRow(ctx, query, id, productID)
+
+	variant, err := scanVariant(row)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, domain.ErrNotFound
+		}
+		slog.ErrorContext(ctx, "[variantRepository] GetByID", "scan", err)
+		return nil, domain.ErrInternal
+	}
+
+	return variant, nil
+}
+
+func (r *variantRepository) GetByProductID(ctx context.Context, productID int64) ([]*domain.ProductVariant, error) {
+	query := `SELECT id, product_id, sku, options, price, image_url, created_at, updated_at FROM product_variants WHERE product_id = $1 ORDER BY id`
+	rows, err := conn(ctx, r.conn).Query(ctx, query, productID)
+	if err != nil {
+		slog.ErrorContext(ctx, "[variantRepository] GetByProductID", "query", err)
+		return nil, domain.ErrInternal
+	}
+	defer rows.Close()
+
+	var variants []*domain.ProductVariant
+	for rows.Next() {
+		variant, err := scanVariant(rows)
+		if err != nil {
+			slog.ErrorContext(ctx, "[variantRepository] GetByProductID", "scan", err)
+			return nil, domain.ErrInternal
+		}
+		variants = append(variants, variant)
+	}
+
+	return variants, nil
+}
+
+func (r *variantRepository) GetByProductIDs(ctx context.Context, productIDs []int64) (map[int64][]*domain.ProductVariant, error) {
+	variants := make(map[int64][]*domain.ProductVariant, len(productIDs))
+	if len(productIDs) == 0 {
+		return variants, nil
+	}
+
+	query := `SELECT id, product_id, sku, options, price, image_url, created_at, updated_at FROM product_variants WHERE product_id = ANY($1) ORDER BY product_id, id`
+	rows, err := conn(ctx, r.conn).Query(ctx, query, productIDs)
+	if err != nil {
+		slog.ErrorContext(ctx, "[variantRepository] GetByProductIDs", "query", err)
+		return nil, domain.ErrInternal
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		variant, err := scanVariant(rows)
+		if err != nil {
+			slog.ErrorContext(ctx, "[variantRepository] GetByProductIDs", "scan", err)
+			return nil, domain.ErrInternal
+		}
+		variants[variant.ProductID] = append(variants[variant.ProductID], variant)
+	}
+
+	return variants, nil
+}
+
+func (r *variantRepository) Create(ctx context.Context, variant *domain.ProductVariant) error {
+	options, err := json.Marshal(variant.Options)
+	if err != nil {
+		slog.ErrorContext(ctx, "[variantRepository] Create", "json.Marshal", err)
+		return domain.ErrInternal
+	}
+
+	query := `INSERT INTO product_variants (product_id, sku, options, price, image_url)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at, updated_at`
+
+	err = conn(ctx, r.conn).QueryRow(ctx, query,
+		variant.ProductID,
+		variant.SKU,
+		options,
+		variant.Price,
+		variant.ImageURL).
+		Scan(&variant.ID, &variant.CreatedAt, &variant.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.ErrConflict
+		}
+		slog.ErrorContext(ctx, "[variantRepository] Create", "scan", err)
+		return domain.ErrInternal
+	}
+
+	return nil
+}
+
+func (r *variantRepository) Update(ctx context.Context, variant *domain.ProductVariant) error {
+	options, err := json.Marshal(variant.Options)
+	if err != nil {
+		slog.ErrorContext(ctx, "[variantRepository] Update", "json.Marshal", err)
+		return domain.ErrInternal
+	}
+
+	query := `UPDATE product_variants SET sku = $1, options = $2, price = $3, image_url = $4, updated_at = NOW()
+		WHERE id = $5 AND product_id = $6 RETURNING updated_at`
+
+	err = conn(ctx, r.conn).QueryRow(ctx, query,
+		variant.SKU,
+		options,
+		variant.Price,
+		variant.ImageURL,
+		variant.ID,
+		variant.ProductID).
+		Scan(&variant.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return domain.ErrNotFound
+		}
+		if isUniqueViolation(err) {
+			return domain.ErrConflict
+		}
+		slog.ErrorContext(ctx, "[variantRepository] Update", "scan", err)
+		return domain.ErrInternal
+	}
+
+	return nil
+}
+
+func (r *variantRepository) Delete(ctx context.Context, productID, id int64) error {
+	query := `DELETE FROM product_variants WHERE id = $1 AND product_id = $2`
+	res, err := conn(ctx, r.conn).Exec(ctx, query, id, productID)
+	if err != nil {
+		slog.ErrorContext(ctx, "[variantRepository] Delete", "exec", err)
+		return domain.ErrInternal
+	}
+
+	if res.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanVariant(row rowScanner) (*domain.ProductVariant, error) {
+	var variant domain.ProductVariant
+	var options []byte
+	var price sql.NullInt64
+	if err := row.Scan(&variant.ID, &variant.ProductID, &variant.SKU, &options, &price, &variant.ImageURL, &variant.CreatedAt, &variant.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(options, &variant.Options); err != nil {
+		return nil, err
+	}
+	if price.Valid {
+		variant.Price = &price.Int64
+	}
+
+	return &variant, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation
+}
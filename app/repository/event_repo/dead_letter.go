@@ -0,0 +1,155 @@
+package eventrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"product-service/app/domain"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	headerRequestID        = "X-Request-ID"
+	headerOriginalSubject  = "Dlq-Original-Subject"
+	headerOriginalSequence = "Dlq-Original-Sequence"
+	headerReason           = "Dlq-Reason"
+	headerDeliveries       = "Dlq-Deliveries"
+)
+
+// deadLetterRepository keeps dead letters as messages on the dead letter
+// subject of the stream the failed messages came from.
+type deadLetterRepository struct {
+	js      jetstream.JetStream
+	stream  jetstream.Stream
+	subject string
+}
+
+func NewDeadLetterRepository(js jetstream.JetStream, stream jetstream.Stream, subject string) domain.DeadLetterRepository {
+	return &deadLetterRepository{
+		js:      js,
+		stream:  stream,
+		subject: subject,
+	}
+}
+
+func (r *deadLetterRepository) Publish(ctx context.Context, letter *domain.DeadLetter) error {
+	msg := nats.NewMsg(r.subject)
+	msg.Data = []byte(letter.Data)
+	msg.Header.Set(headerOriginalSubject, letter.Subject)
+	msg.Header.Set(headerOriginalSequence, strconv.FormatUint(letter.OriginalSequence, 10))
+	msg.Header.Set(headerReason, letter.Reason)
+	msg.Header.Set(headerDeliveries, strconv.FormatUint(letter.Deliveries, 10))
+	if letter.RequestID != "" {
+		msg.Header.Set(headerRequestID, letter.RequestID)
+	}
+
+	// the original sequence makes publishing the same failure twice harmless
+	ack, err := r.js.PublishMsg(ctx, msg, jetstream.WithMsgID(fmt.Sprintf("dlq:%d", letter.OriginalSequence)))
+	if err != nil {
+		slog.ErrorContext(ctx, "[deadLetterRepository] Publish", "originalSequence", letter.OriginalSequence, "error", err)
+		return err
+	}
+
+	letter.Sequence = ack.Sequence
+	return nil
+}
+
+func (r *deadLetterRepository) List(ctx context.Context, afterSequence uint64, limit int) ([]*domain.DeadLetter, error) {
+	cfg := jetstream.OrderedConsumerConfig{
+		FilterSubjects: []string{r.subject},
+		DeliverPolicy:  jetstream.DeliverAllPolicy,
+	}
+	if afterSequence > 0 {
+		cfg.DeliverPolicy = jetstream.DeliverByStartSequencePolicy
+		cfg.OptStartSeq = afterSequence + 1
+	}
+
+	cons, err := r.stream.OrderedConsumer(ctx, cfg)
+	if err != nil {
+		slog.ErrorContext(ctx, "[deadLetterRepository] List", "OrderedConsumer", err)
+		return nil, domain.ErrInternal
+	}
+
+	batch, err := cons.FetchNoWait(limit)
+	if err != nil {
+		slog.ErrorContext(ctx, "[deadLetterRepository] List", "FetchNoWait", err)
+		return nil, domain.ErrInternal
+	}
+
+	letters := []*domain.DeadLetter{}
+	for msg := range batch.Messages() {
+		meta, err := msg.Metadata()
+		if err != nil {
+			slog.ErrorContext(ctx, "[deadLetterRepository] List", "Metadata", err)
+			return nil, domain.ErrInternal
+		}
+		letters = append(letters, toDeadLetter(meta.Sequence.Stream, msg.Headers(), msg.Data(), meta.Timestamp))
+	}
+	if err := batch.Error(); err != nil {
+		slog.ErrorContext(ctx, "[deadLetterRepository] List", "batch", err)
+		return nil, domain.ErrInternal
+	}
+
+	return letters, nil
+}
+
+func (r *deadLetterRepository) Get(ctx context.Context, sequence uint64) (*domain.DeadLetter, error) {
+	msg, err := r.stream.GetMsg(ctx, sequence)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrMsgNotFound) {
+			return nil, domain.ErrNotFound
+		}
+		slog.ErrorContext(ctx, "[deadLetterRepository] Get", "sequence", sequence, "error", err)
+		return nil, domain.ErrInternal
+	}
+	if msg.Subject != r.subject {
+		return nil, domain.ErrNotFound
+	}
+
+	return toDeadLetter(msg.Sequence, msg.Header, msg.Data, msg.Time), nil
+}
+
+func (r *deadLetterRepository) Delete(ctx context.Context, sequence uint64) error {
+	if err := r.stream.DeleteMsg(ctx, sequence); err != nil {
+		if errors.Is(err, jetstream.ErrMsgNotFound) {
+			return domain.ErrNotFound
+		}
+		slog.ErrorContext(ctx, "[deadLetterRepository] Delete", "sequence", sequence, "error", err)
+		return domain.ErrInternal
+	}
+	return nil
+}
+
+func (r *deadLetterRepository) Republish(ctx context.Context, letter *domain.DeadLetter) error {
+	msg := nats.NewMsg(letter.Subject)
+	msg.Data = []byte(letter.Data)
+	if letter.RequestID != "" {
+		msg.Header.Set(headerRequestID, letter.RequestID)
+	}
+
+	if _, err := r.js.PublishMsg(ctx, msg, jetstream.WithMsgID(fmt.Sprintf("replay:%d", letter.Sequence))); err != nil {
+		slog.ErrorContext(ctx, "[deadLetterRepository] Republish", "sequence", letter.Sequence, "error", err)
+		return domain.ErrInternal
+	}
+	return nil
+}
+
+func toDeadLetter(sequence uint64, header nats.Header, data []byte, failedAt time.Time) *domain.DeadLetter {
+	originalSequence, _ := strconv.ParseUint(header.Get(headerOriginalSequence), 10, 64)
+	deliveries, _ := strconv.ParseUint(header.Get(headerDeliveries), 10, 64)
+	return &domain.DeadLetter{
+		Sequence:         sequence,
+		Subject:          header.Get(headerOriginalSubject),
+		OriginalSequence: originalSequence,
+		Reason:           header.Get(headerReason),
+		Deliveries:       deliveries,
+		RequestID:        header.Get(headerRequestID),
+		Data:             string(data),
+		FailedAt:         failedAt,
+	}
+}
@@ -0,0 +1,57 @@
+package eventrepo
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"product-service/app/domain"
+	"strconv"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+type productEventPublisher struct {
+	js            jetstream.JetStream
+	subjectPrefix string
+}
+
+func NewProductEventPublisher(js jetstream.JetStream, subjectPrefix string) domain.ProductEventPublisher {
+	return &productEventPublisher{
+		js:            js,
+		subjectPrefix: subjectPrefix,
+	}
+}
+
+// Publish sends event to <prefix>.<action>, e.g. product.price_changed.
+func (p *productEventPublisher) Publish(ctx context.Context, event domain.ProductEvent, requestID string) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		slog.ErrorContext(ctx, "[productEventPublisher] Publish", "json.Marshal", err)
+		return err
+	}
+
+	msg := nats.NewMsg(p.subject(event.Type))
+	msg.Data = data
+	msg.Header.Set(jetstream.MsgIDHeader, event.ID)
+	msg.Header.Set("Content-Type", "application/json")
+	msg.Header.Set("Event-Type", event.Type)
+	msg.Header.Set("Schema-Version", strconv.Itoa(event.SchemaVersion))
+	if requestID != "" {
+		msg.Header.Set(headerRequestID, requestID)
+	}
+
+	ack, err := p.js.PublishMsg(ctx, msg)
+	if err != nil {
+		slog.ErrorContext(ctx, "[productEventPublisher] Publish", "eventID", event.ID, "error", err)
+		return err
+	}
+
+	slog.InfoContext(ctx, "[productEventPublisher] Published", "eventID", event.ID, "subject", msg.Subject, "sequence", ack.Sequence, "duplicate", ack.Duplicate)
+	return nil
+}
+
+func (p *productEventPublisher) subject(eventType string) string {
+	return p.subjectPrefix + "." + strings.TrimPrefix(eventType, "product.")
+}
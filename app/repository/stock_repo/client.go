@@ -0,0 +1,198 @@
+package stockrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	neturl "net/url"
+	"product-service/app/domain"
+	"product-service/config"
+	"product-service/pkg"
+	"product-service/pkg/metrics"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+const (
+	defaultWarehouseTimeout       = 2 * time.Second
+	defaultWarehouseMaxRetries    = 2
+	defaultWarehouseRetryBackoff  = 100 * time.Millisecond
+	defaultWarehouseBreakerFails  = 5
+	defaultWarehouseBreakerPeriod = 30 * time.Second
+)
+
+// errWarehouseUnhealthy marks failures that say something about the health
+// of the warehouse service. Only those count towards opening the breaker.
+var errWarehouseUnhealthy = errors.New("warehouse service unhealthy")
+
+// warehouseClient calls the warehouse service with a timeout per attempt,
+// jittered retries for GET requests and a circuit breaker that fails fast
+// while the service is unhealthy.
+type warehouseClient struct {
+	httpClient         *http.Client
+	baseURL            string
+	internalAuthHeader string
+	timeout            time.Duration
+	maxRetries         int
+	retryBackoff       time.Duration
+	breaker            *gobreaker.CircuitBreaker
+}
+
+func newWarehouseClient(cfg config.WarehouseServiceConfig, internalAuthHeader string) *warehouseClient {
+	c := &warehouseClient{
+		httpClient:         &http.Client{Transport: &instrumentedTransport{next: http.DefaultTransport}},
+		baseURL:            cfg.Host,
+		internalAuthHeader: internalAuthHeader,
+		timeout:            time.Duration(cfg.TimeoutMs) * time.Millisecond,
+		maxRetries:         cfg.MaxRetries,
+		retryBackoff:       time.Duration(cfg.RetryBackoffMs) * time.Millisecond,
+	}
+	if c.timeout <= 0 {
+		c.timeout = defaultWarehouseTimeout
+	}
+	if c.maxRetries <= 0 {
+		c.maxRetries = defaultWarehouseMaxRetries
+	}
+	if c.retryBackoff <= 0 {
+		c.retryBackoff = defaultWarehouseRetryBackoff
+	}
+
+	failures := cfg.BreakerFailures
+	if failures == 0 {
+		failures = defaultWarehouseBreakerFails
+	}
+	openPeriod := time.Duration(cfg.BreakerOpenSeconds) * time.Second
+	if openPeriod <= 0 {
+		openPeriod = defaultWarehouseBreakerPeriod
+	}
+	c.breaker = gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		Name:    "warehouse-service",
+		Timeout: openPeriod,
+		IsSuccessful: func(err error) bool {
+			return err == nil || !errors.Is(err, errWarehouseUnhealthy)
+		},
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= failures
+		},
+		OnStateChange: func(name string, from, to gobreaker.State) {
+			slog.Warn("[warehouseClient] circuit breaker state changed", "name", name, "from", from.String(), "to", to.String())
+		},
+	})
+
+	return c
+}
+
+// fetchJSON GETs url through the circuit breaker and decodes the response
+// data into v. Failures that say the warehouse is unhealthy, an open breaker
+// included, are reported as domain.ErrStockUnavailable. Any other error, e.g.
+// a 404 or an undecodable body, is returned as is.
+func fetchJSON[T any](ctx context.Context, c *warehouseClient, url string, v T) error {
+	_, err := c.breaker.Execute(func() (any, error) {
+		return nil, getWithRetry(ctx, c, url, v)
+	})
+	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+		if parsed, parseErr := neturl.Parse(url); parseErr == nil {
+			metrics.WarehouseError(http.MethodGet, warehouseRoute(parsed), "circuit_open")
+		}
+		return fmt.Errorf("%w: circuit breaker is %s", domain.ErrStockUnavailable, c.breaker.State())
+	}
+	if errors.Is(err, errWarehouseUnhealthy) {
+		return fmt.Errorf("%w: %v", domain.ErrStockUnavailable, err)
+	}
+	return err
+}
+
+func getWithRetry[T any](ctx context.Context, c *warehouseClient, url string, v T) error {
+	for attempt := 0; ; attempt++ {
+		retry, err := getOnce(ctx, c, url, v)
+		if err == nil || !retry || attempt >= c.maxRetries {
+			return err
+		}
+
+		// full jitter: a random delay up to the exponential backoff
+		delay := time.Duration(rand.Int63n(int64(c.retryBackoff << attempt)))
+		slog.WarnContext(ctx, "[warehouseClient] retrying request", "url", url, "attempt", attempt+1, "delay", delay, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// getOnce performs a single attempt and reports whether a failure is worth
+// retrying. Only transport errors, 429 and 5xx responses are. A request
+// aborted because ctx ended is neither retried nor blamed on the warehouse;
+// running into the timeout of the attempt is.
+func getOnce[T any](ctx context.Context, c *warehouseClient, url string, v T) (bool, error) {
+	attemptCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(attemptCtx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	pkg.AddRequestHeader(attemptCtx, c.internalAuthHeader, req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, err
+		}
+		return true, fmt.Errorf("%w: %v", errWarehouseUnhealthy, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+		io.Copy(io.Discard, resp.Body)
+		return true, fmt.Errorf("%w: status %d", errWarehouseUnhealthy, resp.StatusCode)
+	}
+
+	if err := pkg.DecodeResponseBody(resp, v); err != nil {
+		return false, fmt.Errorf("status %d: %w", resp.StatusCode, err)
+	}
+	return false, nil
+}
+
+// instrumentedTransport records the latency and failures of every request to
+// the warehouse service, retries included.
+type instrumentedTransport struct {
+	next http.RoundTripper
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	route := warehouseRoute(req.URL)
+	start := time.Now()
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		metrics.WarehouseError(req.Method, route, "transport")
+		return nil, err
+	}
+
+	metrics.WarehouseRequest(req.Method, route, resp.StatusCode, time.Since(start))
+	if resp.StatusCode >= http.StatusBadRequest {
+		metrics.WarehouseError(req.Method, route, "status")
+	}
+	return resp, nil
+}
+
+// warehouseRoute replaces the ids in the path of u with :id and drops the
+// query, so every product shares one series.
+func warehouseRoute(u *neturl.URL) string {
+	segments := strings.Split(u.Path, "/")
+	for i, segment := range segments {
+		if _, err := strconv.ParseInt(segment, 10, 64); err == nil {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
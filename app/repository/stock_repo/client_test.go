@@ -0,0 +1,83 @@
+package stockrepo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"product-service/app/domain"
+	"product-service/config"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetchJSONOnlyDegradesUnhealthyWarehouse(t *testing.T) {
+	tests := []struct {
+		name            string
+		status          int
+		body            string
+		wantErr         bool
+		wantUnavailable bool
+		wantCalls       int32
+	}{
+		{name: "ok", status: http.StatusOK, body: `{"success":true,"data":{"product_id":1,"available_stock":3}}`, wantCalls: 1},
+		{name: "server error", status: http.StatusBadGateway, wantErr: true, wantUnavailable: true, wantCalls: 3},
+		{name: "not found", status: http.StatusNotFound, body: `{"success":false,"error":"not found"}`, wantErr: true, wantCalls: 1},
+		{name: "undecodable body", status: http.StatusOK, body: `<html>`, wantErr: true, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			c := newWarehouseClient(config.WarehouseServiceConfig{Host: server.URL, RetryBackoffMs: 1}, "")
+			var data AvailableProductStockResponse
+			err := fetchJSON(context.Background(), c, server.URL+"/internal/warehouse-service/products/1/stocks", &data)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if got := errors.Is(err, domain.ErrStockUnavailable); got != tt.wantUnavailable {
+				t.Fatalf("err = %v, stock unavailable = %v, want %v", err, got, tt.wantUnavailable)
+			}
+			if !tt.wantErr && data.AvailableStock != 3 {
+				t.Fatalf("data = %+v, want stock 3", data)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Fatalf("warehouse was called %d times, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestFetchJSONDoesNotBlameWarehouseForCancelledRequest(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	c := newWarehouseClient(config.WarehouseServiceConfig{Host: server.URL, BreakerFailures: 1}, "")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	var data AvailableProductStockResponse
+	err := fetchJSON(ctx, c, server.URL+"/internal/warehouse-service/products/1/stocks", &data)
+	if !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, domain.ErrStockUnavailable) {
+		t.Fatalf("err = %v, want the context error without stock unavailable", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("warehouse was called %d times, want 1", got)
+	}
+	if state := c.breaker.State().String(); state != "closed" {
+		t.Fatalf("breaker is %s, want closed", state)
+	}
+}
@@ -4,3 +4,8 @@ type AvailableProductStockResponse struct {
 	ProductID      int64 `json:"product_id"`
 	AvailableStock int64 `json:"available_stock"`
 }
+
+type AvailableVariantStockResponse struct {
+	VariantID      int64 `json:"variant_id"`
+	AvailableStock int64 `json:"available_stock"`
+}
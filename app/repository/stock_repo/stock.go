@@ -4,63 +4,101 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math/rand"
 	"net/http"
 	"product-service/app/domain"
+	"product-service/config"
 	"product-service/pkg"
+	"product-service/pkg/metrics"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+// releaseLockScript deletes a lock only while it still holds the caller's
+// token, so an expired lock taken over by another replica is left alone.
+var releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// casStockScript sets the stock in KEYS[1] only when ARGV[2] is newer than the
+// version kept in KEYS[2]. ARGV[3] is the TTL in milliseconds, 0 for none.
+// The version outlives the stock so a late message cannot resurrect an old
+// value after expiry.
+var casStockScript = redis.NewScript(`
+local current = tonumber(redis.call("GET", KEYS[2]) or "0")
+local version = tonumber(ARGV[2])
+if version <= current then
+	return 0
+end
+local ttl = tonumber(ARGV[3])
+if ttl > 0 then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ttl)
+else
+	redis.call("SET", KEYS[1], ARGV[1])
+end
+redis.call("SET", KEYS[2], ARGV[2])
+return 1
+`)
+
+// Labels of the stock cache metrics.
+const (
+	stockKindProduct = "product"
+	stockKindVariant = "variant"
+	cacheHit         = "hit"
+	cacheMiss        = "miss"
+	cacheError       = "error"
+)
+
+// idempotencyKeyHeader lets the warehouse recognise a repeated stock init.
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// defaultStockTTL bounds how long a cached stock can be wrong when a stock
+// message is lost.
+const defaultStockTTL = 5 * time.Minute
+
 type stockRepository struct {
-	redis              *redis.Client
-	ttl                time.Duration
-	httpClient         *http.Client
-	baseURL            string
-	internalAuthHeader string
+	redis     *redis.Client
+	ttl       time.Duration
+	warehouse *warehouseClient
 }
 
-func NewStockRepository(redis *redis.Client, ttl time.Duration, baseURL string, internalAuthHeader string) domain.StockRepository {
+func NewStockRepository(redis *redis.Client, ttl time.Duration, warehouse config.WarehouseServiceConfig, internalAuthHeader string) domain.StockRepository {
+	if ttl <= 0 {
+		ttl = defaultStockTTL
+	}
 	return &stockRepository{
-		redis:              redis,
-		ttl:                ttl,
-		httpClient:         &http.Client{Timeout: 30 * time.Second},
-		baseURL:            baseURL,
-		internalAuthHeader: internalAuthHeader,
+		redis:     redis,
+		ttl:       ttl,
+		warehouse: newWarehouseClient(warehouse, internalAuthHeader),
 	}
 }
 
 func (r *stockRepository) GetStock(ctx context.Context, productID int64) (int, error) {
 	stock, err := r.redis.Get(ctx, r.key(productID)).Int()
 	if err != nil {
+		metrics.StockCacheLookup(stockKindProduct, cacheResult(err))
 		slog.WarnContext(ctx, "[GetStock] Cache miss or error retrieving stock", "productID", productID, "error", err)
 		return 0, fmt.Errorf("cache miss: %w", err)
 	}
+	metrics.StockCacheLookup(stockKindProduct, cacheHit)
 	return stock, nil
 }
 
 func (r *stockRepository) FetchStockFromService(ctx context.Context, productID int64) (int, error) {
-	url := fmt.Sprintf("%s/internal/warehouse-service/products/%d/stocks", r.baseURL, productID)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		slog.ErrorContext(ctx, "[FetchStockFromService] Failed to create HTTP request", "productID", productID, "error", err)
-		return 0, err
-	}
-
-	pkg.AddRequestHeader(ctx, r.internalAuthHeader, req)
-
-	resp, err := r.httpClient.Do(req)
-	if err != nil {
-		slog.ErrorContext(ctx, "[FetchStockFromService] HTTP request failed", "productID", productID, "error", err)
-		return 0, err
-	}
-	defer resp.Body.Close()
+	url := fmt.Sprintf("%s/internal/warehouse-service/products/%d/stocks", r.warehouse.baseURL, productID)
 
 	var data AvailableProductStockResponse
-	if err := pkg.DecodeResponseBody(resp, &data); err != nil {
-		slog.ErrorContext(ctx, "[FetchStockFromService] Failed to decode response body", "productID", productID, "error", err)
+	if err := fetchJSON(ctx, r.warehouse, url, &data); err != nil {
+		slog.ErrorContext(ctx, "[FetchStockFromService] Failed to fetch stock", "productID", productID, "error", err)
 		return 0, err
 	}
 
@@ -78,32 +116,288 @@ func (r *stockRepository) CacheStock(ctx context.Context, productID int64, stock
 	return nil
 }
 
+// FillStock caches stock read from the warehouse unless a stock update was
+// cached while the read was in flight.
+func (r *stockRepository) FillStock(ctx context.Context, productID int64, stock int) error {
+	filled, err := r.redis.SetNX(ctx, r.key(productID), stock, r.ttl).Result()
+	if err != nil {
+		slog.ErrorContext(ctx, "[FillStock] Failed to cache stock", "productID", productID, "stock", stock, "error", err)
+		return err
+	}
+	slog.InfoContext(ctx, "[FillStock] Stock cache filled", "productID", productID, "stock", stock, "filled", filled)
+	return nil
+}
+
+// GetStocks reads the cached stock of many products with a single MGET. Cache
+// misses are left out of the result.
+func (r *stockRepository) GetStocks(ctx context.Context, productIDs []int64) (map[int64]int, error) {
+	return r.getMany(ctx, stockKindProduct, productIDs, r.key)
+}
+
+// GetVariantStocks reads the cached stock of many variants with a single
+// MGET. Cache misses are left out of the result.
+func (r *stockRepository) GetVariantStocks(ctx context.Context, variantIDs []int64) (map[int64]int, error) {
+	return r.getMany(ctx, stockKindVariant, variantIDs, r.variantKey)
+}
+
+func (r *stockRepository) getMany(ctx context.Context, kind string, ids []int64, key func(int64) string) (map[int64]int, error) {
+	stocks := make(map[int64]int, len(ids))
+	if len(ids) == 0 {
+		return stocks, nil
+	}
+
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, key(id))
+	}
+
+	values, err := r.redis.MGet(ctx, keys...).Result()
+	if err != nil {
+		metrics.StockCacheLookups(kind, cacheError, len(keys))
+		slog.WarnContext(ctx, "[GetStocks] Error retrieving stocks", "kind", kind, "ids", ids, "error", err)
+		return nil, err
+	}
+
+	for i, value := range values {
+		raw, ok := value.(string)
+		if !ok {
+			continue
+		}
+		stock, err := strconv.Atoi(raw)
+		if err != nil {
+			slog.WarnContext(ctx, "[GetStocks] Invalid cached stock", "kind", kind, "id", ids[i], "value", raw)
+			continue
+		}
+		stocks[ids[i]] = stock
+	}
+
+	metrics.StockCacheLookups(kind, cacheHit, len(stocks))
+	metrics.StockCacheLookups(kind, cacheMiss, len(keys)-len(stocks))
+	return stocks, nil
+}
+
+// FetchStocksFromService asks the warehouse service for the stock of many
+// products in one request.
+func (r *stockRepository) FetchStocksFromService(ctx context.Context, productIDs []int64) (map[int64]int, error) {
+	url := fmt.Sprintf("%s/internal/warehouse-service/products/stocks?product_ids=%s", r.warehouse.baseURL, joinIDs(productIDs))
+
+	var data []AvailableProductStockResponse
+	if err := fetchJSON(ctx, r.warehouse, url, &data); err != nil {
+		slog.ErrorContext(ctx, "[FetchStocksFromService] Failed to fetch stocks", "productIDs", productIDs, "error", err)
+		return nil, err
+	}
+
+	stocks := make(map[int64]int, len(data))
+	for _, item := range data {
+		stocks[item.ProductID] = int(item.AvailableStock)
+	}
+
+	slog.InfoContext(ctx, "[FetchStocksFromService] Stocks fetched from warehouse service", "requested", len(productIDs), "received", len(stocks))
+	return stocks, nil
+}
+
+// FillStocks is FillStock for many products in one round trip.
+func (r *stockRepository) FillStocks(ctx context.Context, stocks map[int64]int) error {
+	return r.fillMany(ctx, stocks, r.key)
+}
+
+// FillVariantStocks is FillStocks for variants.
+func (r *stockRepository) FillVariantStocks(ctx context.Context, stocks map[int64]int) error {
+	return r.fillMany(ctx, stocks, r.variantKey)
+}
+
+func (r *stockRepository) fillMany(ctx context.Context, stocks map[int64]int, key func(int64) string) error {
+	if len(stocks) == 0 {
+		return nil
+	}
+
+	pipe := r.redis.Pipeline()
+	for id, stock := range stocks {
+		pipe.SetNX(ctx, key(id), stock, r.ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.ErrorContext(ctx, "[FillStocks] Failed to cache stocks", "count", len(stocks), "error", err)
+		return err
+	}
+
+	slog.InfoContext(ctx, "[FillStocks] Stock cache filled", "count", len(stocks))
+	return nil
+}
+
+// AcquireStockLock takes the short lived lock guarding a warehouse fetch of
+// the product stock. It returns the lock token, or an empty token when
+// another replica holds the lock.
+func (r *stockRepository) AcquireStockLock(ctx context.Context, productID int64, ttl time.Duration) (string, error) {
+	token := strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + strconv.FormatInt(rand.Int63(), 36)
+	acquired, err := r.redis.SetNX(ctx, r.lockKey(productID), token, ttl).Result()
+	if err != nil {
+		slog.ErrorContext(ctx, "[AcquireStockLock] Failed to acquire lock", "productID", productID, "error", err)
+		return "", err
+	}
+	if !acquired {
+		return "", nil
+	}
+	return token, nil
+}
+
+func (r *stockRepository) ReleaseStockLock(ctx context.Context, productID int64, token string) error {
+	if err := releaseLockScript.Run(ctx, r.redis, []string{r.lockKey(productID)}, token).Err(); err != nil {
+		slog.ErrorContext(ctx, "[ReleaseStockLock] Failed to release lock", "productID", productID, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (r *stockRepository) lockKey(productID int64) string {
+	return fmt.Sprintf("lock:stock:product:%d", productID)
+}
+
+// joinIDs formats ids as the comma separated list the batch endpoints take.
+func joinIDs(ids []int64) string {
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, strconv.FormatInt(id, 10))
+	}
+	return strings.Join(parts, ",")
+}
+
+// cacheResult tells a missing key apart from a failing lookup.
+func cacheResult(err error) string {
+	if errors.Is(err, redis.Nil) {
+		return cacheMiss
+	}
+	return cacheError
+}
+
 func (r *stockRepository) key(productID int64) string {
 	return fmt.Sprintf("stock:product:%d", productID)
 }
 
-func (r *stockRepository) InitStockToWarehouse(ctx context.Context, warehouse domain.InitStockRequest) error {
-	url := fmt.Sprintf("%s/internal/warehouse-service/stocks", r.baseURL)
+func (r *stockRepository) GetVariantStock(ctx context.Context, variantID int64) (int, error) {
+	stock, err := r.redis.Get(ctx, r.variantKey(variantID)).Int()
+	if err != nil {
+		metrics.StockCacheLookup(stockKindVariant, cacheResult(err))
+		slog.WarnContext(ctx, "[GetVariantStock] Cache miss or error retrieving stock", "variantID", variantID, "error", err)
+		return 0, fmt.Errorf("cache miss: %w", err)
+	}
+	metrics.StockCacheLookup(stockKindVariant, cacheHit)
+	return stock, nil
+}
+
+func (r *stockRepository) FetchVariantStockFromService(ctx context.Context, variantID int64) (int, error) {
+	url := fmt.Sprintf("%s/internal/warehouse-service/variants/%d/stocks", r.warehouse.baseURL, variantID)
+
+	var data AvailableVariantStockResponse
+	if err := fetchJSON(ctx, r.warehouse, url, &data); err != nil {
+		slog.ErrorContext(ctx, "[FetchVariantStockFromService] Failed to fetch stock", "variantID", variantID, "error", err)
+		return 0, err
+	}
+
+	slog.InfoContext(ctx, "[FetchVariantStockFromService] Stock fetched from warehouse service", "variantID", variantID, "stock", data.AvailableStock)
+	return int(data.AvailableStock), nil
+}
+
+// FetchVariantStocksFromService asks the warehouse service for the stock of
+// many variants in one request.
+func (r *stockRepository) FetchVariantStocksFromService(ctx context.Context, variantIDs []int64) (map[int64]int, error) {
+	url := fmt.Sprintf("%s/internal/warehouse-service/variants/stocks?variant_ids=%s", r.warehouse.baseURL, joinIDs(variantIDs))
+
+	var data []AvailableVariantStockResponse
+	if err := fetchJSON(ctx, r.warehouse, url, &data); err != nil {
+		slog.ErrorContext(ctx, "[FetchVariantStocksFromService] Failed to fetch stocks", "variantIDs", variantIDs, "error", err)
+		return nil, err
+	}
+
+	stocks := make(map[int64]int, len(data))
+	for _, item := range data {
+		stocks[item.VariantID] = int(item.AvailableStock)
+	}
+
+	slog.InfoContext(ctx, "[FetchVariantStocksFromService] Stocks fetched from warehouse service", "requested", len(variantIDs), "received", len(stocks))
+	return stocks, nil
+}
+
+func (r *stockRepository) CacheVariantStock(ctx context.Context, variantID int64, stock int) error {
+	err := r.redis.Set(ctx, r.variantKey(variantID), stock, r.ttl).Err()
+	if err != nil {
+		slog.ErrorContext(ctx, "[CacheVariantStock] Failed to cache stock", "variantID", variantID, "stock", stock, "error", err)
+		return err
+	}
+	slog.InfoContext(ctx, "[CacheVariantStock] Stock cached successfully", "variantID", variantID, "stock", stock)
+	return nil
+}
+
+// FillVariantStock is FillStock for a variant.
+func (r *stockRepository) FillVariantStock(ctx context.Context, variantID int64, stock int) error {
+	filled, err := r.redis.SetNX(ctx, r.variantKey(variantID), stock, r.ttl).Result()
+	if err != nil {
+		slog.ErrorContext(ctx, "[FillVariantStock] Failed to cache stock", "variantID", variantID, "stock", stock, "error", err)
+		return err
+	}
+	slog.InfoContext(ctx, "[FillVariantStock] Stock cache filled", "variantID", variantID, "stock", stock, "filled", filled)
+	return nil
+}
+
+func (r *stockRepository) CompareAndSetStock(ctx context.Context, productID int64, stock int, kind string, version int64) (bool, error) {
+	return r.compareAndSet(ctx, r.key(productID), stock, kind, version)
+}
+
+func (r *stockRepository) CompareAndSetVariantStock(ctx context.Context, variantID int64, stock int, kind string, version int64) (bool, error) {
+	return r.compareAndSet(ctx, r.variantKey(variantID), stock, kind, version)
+}
+
+// compareAndSet keeps the last applied key of each ordering kind under its
+// own name, e.g. stock:product:1:version and stock:product:1:occurred_at.
+func (r *stockRepository) compareAndSet(ctx context.Context, key string, stock int, kind string, version int64) (bool, error) {
+	applied, err := casStockScript.Run(ctx, r.redis, []string{key, key + ":" + kind}, stock, version, r.ttl.Milliseconds()).Int()
+	if err != nil {
+		slog.ErrorContext(ctx, "[CompareAndSetStock] Failed to cache stock", "key", key, "stock", stock, "kind", kind, "version", version, "error", err)
+		return false, err
+	}
+	return applied == 1, nil
+}
+
+func (r *stockRepository) variantKey(variantID int64) string {
+	return fmt.Sprintf("stock:variant:%d", variantID)
+}
+
+// InitStockToWarehouse creates the stock record of a product or variant. The
+// outbox delivers it at least once, so the warehouse is expected to answer a
+// repeated Idempotency-Key with the original result, and 409 Conflict when
+// the stock already exists. Both count as success here.
+func (r *stockRepository) InitStockToWarehouse(ctx context.Context, warehouse domain.InitStockRequest, idempotencyKey string) error {
+	url := fmt.Sprintf("%s/internal/warehouse-service/stocks", r.warehouse.baseURL)
 	reqBody, err := json.Marshal(warehouse)
 	if err != nil {
 		slog.ErrorContext(ctx, "[stockRepository] InitStockToWarehouse", "json.Marshal", err)
 		return err
 	}
+	// not retried here, the outbox relay retries with the same key
+	ctx, cancel := context.WithTimeout(ctx, r.warehouse.timeout)
+	defer cancel()
+
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(reqBody))
 	if err != nil {
 		slog.ErrorContext(ctx, "[stockRepository] InitStockToWarehouse", "http.NewRequestWithContext", err)
 		return err
 	}
 
-	pkg.AddRequestHeader(ctx, r.internalAuthHeader, httpReq)
+	pkg.AddRequestHeader(ctx, r.warehouse.internalAuthHeader, httpReq)
+	httpReq.Header.Set(idempotencyKeyHeader, idempotencyKey)
 
-	resp, err := r.httpClient.Do(httpReq)
+	resp, err := r.warehouse.httpClient.Do(httpReq)
 	if err != nil {
 		slog.ErrorContext(ctx, "[stockRepository] InitStockToWarehouse", "httpClient.Do", err)
 		return err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusConflict {
+		slog.InfoContext(ctx, "[stockRepository] InitStockToWarehouse stock already exists",
+			"product_id", warehouse.ProductID, "variant_id", warehouse.VariantID, "idempotency_key", idempotencyKey)
+		return nil
+	}
+
 	var res any
 	if err := pkg.DecodeResponseBody(resp, &res); err != nil {
 		slog.ErrorContext(ctx, "[stockRepository] InitStockToWarehouse", "DecodeResponseBody", err)
@@ -0,0 +1,133 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"product-service/app/domain"
+	"product-service/config"
+	"slices"
+	"strings"
+)
+
+type attributeUsecase struct {
+	attributeRepo domain.AttributeRepository
+	categoryRepo  domain.CategoryRepository
+	cfg           *config.Config
+}
+
+func NewAttributeUsecase(attributeRepo domain.AttributeRepository, categoryRepo domain.CategoryRepository, cfg *config.Config) domain.AttributeUsecase {
+	return &attributeUsecase{attributeRepo, categoryRepo, cfg}
+}
+
+func (u *attributeUsecase) GetSchema(ctx context.Context, category string) ([]*domain.AttributeDefinition, error) {
+	attributes, err := u.attributeRepo.GetByCategory(ctx, slugify(category))
+	if err != nil {
+		slog.ErrorContext(ctx, "[attributeUsecase] GetSchema", "repository", err)
+		return nil, err
+	}
+	if attributes == nil {
+		attributes = []*domain.AttributeDefinition{}
+	}
+
+	return attributes, nil
+}
+
+func (u *attributeUsecase) SetSchema(ctx context.Context, category string, req *domain.SetAttributeSchemaRequest) ([]*domain.AttributeDefinition, error) {
+	// Schemas are keyed by category slug, the same value products store.
+	slug := slugify(category)
+	if slug == "" {
+		return nil, fmt.Errorf("%w: category is empty", domain.ErrValidation)
+	}
+	if _, err := u.categoryRepo.GetBySlug(ctx, slug); err != nil {
+		slog.ErrorContext(ctx, "[attributeUsecase] SetSchema", "category", category, "GetBySlug", err)
+		return nil, err
+	}
+	category = slug
+
+	attributes := make([]*domain.AttributeDefinition, 0, len(req.Attributes))
+	for _, attr := range req.Attributes {
+		if !domain.AttributeCodePattern.MatchString(attr.Code) {
+			return nil, fmt.Errorf("%w: invalid attribute code %q", domain.ErrValidation, attr.Code)
+		}
+
+		var options []string
+		if attr.Type == domain.AttributeTypeEnum {
+			options = attr.Options
+		}
+
+		attributes = append(attributes, &domain.AttributeDefinition{
+			Category: category,
+			Code:     attr.Code,
+			Name:     attr.Name,
+			Type:     attr.Type,
+			Required: attr.Required,
+			Unit:     attr.Unit,
+			Options:  options,
+		})
+	}
+
+	if err := u.attributeRepo.ReplaceForCategory(ctx, category, attributes); err != nil {
+		slog.ErrorContext(ctx, "[attributeUsecase] SetSchema", "repository", err)
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "[attributeUsecase] success SetSchema", "category", category, "attributes", len(attributes))
+	return attributes, nil
+}
+
+// validateAttributes checks product attribute values against the schema of
+// the category and returns them ready to be stored.
+func validateAttributes(ctx context.Context, attributeRepo domain.AttributeRepository, category string, values map[string]any) (map[string]any, error) {
+	schema, err := attributeRepo.GetByCategory(ctx, strings.ToLower(category))
+	if err != nil {
+		slog.ErrorContext(ctx, "[validateAttributes] GetByCategory", "error", err)
+		return nil, err
+	}
+
+	definitions := make(map[string]*domain.AttributeDefinition, len(schema))
+	for _, definition := range schema {
+		definitions[definition.Code] = definition
+	}
+
+	for code, value := range values {
+		definition, ok := definitions[code]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown attribute %q for category %q", domain.ErrValidation, code, category)
+		}
+
+		if !validAttributeValue(definition, value) {
+			return nil, fmt.Errorf("%w: invalid value for attribute %q, expected %s", domain.ErrValidation, code, definition.Type)
+		}
+	}
+
+	for _, definition := range schema {
+		if _, ok := values[definition.Code]; definition.Required && !ok {
+			return nil, fmt.Errorf("%w: attribute %q is required", domain.ErrValidation, definition.Code)
+		}
+	}
+
+	if values == nil {
+		values = map[string]any{}
+	}
+	return values, nil
+}
+
+func validAttributeValue(definition *domain.AttributeDefinition, value any) bool {
+	switch definition.Type {
+	case domain.AttributeTypeString:
+		v, ok := value.(string)
+		return ok && v != ""
+	case domain.AttributeTypeNumber:
+		_, ok := value.(float64)
+		return ok
+	case domain.AttributeTypeEnum:
+		v, ok := value.(string)
+		return ok && slices.Contains(definition.Options, v)
+	case domain.AttributeTypeBool:
+		_, ok := value.(bool)
+		return ok
+	default:
+		return false
+	}
+}
@@ -0,0 +1,76 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"product-service/app/domain"
+	"testing"
+)
+
+type fakeCategoryRepository struct {
+	domain.CategoryRepository
+	slugs map[string]bool
+}
+
+func (r *fakeCategoryRepository) GetBySlug(ctx context.Context, slug string) (*domain.Category, error) {
+	if !r.slugs[slug] {
+		return nil, domain.ErrNotFound
+	}
+	return &domain.Category{Slug: slug}, nil
+}
+
+type fakeAttributeRepository struct {
+	domain.AttributeRepository
+	replaced string
+}
+
+func (r *fakeAttributeRepository) ReplaceForCategory(ctx context.Context, category string, attributes []*domain.AttributeDefinition) error {
+	r.replaced = category
+	return nil
+}
+
+func TestSetSchemaUsesCategorySlug(t *testing.T) {
+	tests := []struct {
+		name     string
+		category string
+		wantSlug string
+		wantErr  error
+	}{
+		{name: "slug", category: "home-garden", wantSlug: "home-garden"},
+		{name: "display name", category: "Home Garden", wantSlug: "home-garden"},
+		{name: "padded", category: "  Home & Garden ", wantSlug: "home-garden"},
+		{name: "unknown", category: "Garden", wantErr: domain.ErrNotFound},
+		{name: "empty", category: " - ", wantErr: domain.ErrValidation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attributeRepo := &fakeAttributeRepository{}
+			categoryRepo := &fakeCategoryRepository{slugs: map[string]bool{"home-garden": true}}
+			u := NewAttributeUsecase(attributeRepo, categoryRepo, nil)
+
+			attributes, err := u.SetSchema(context.Background(), tt.category, &domain.SetAttributeSchemaRequest{
+				Attributes: []domain.AttributeDefinitionRequest{{Code: "material", Name: "Material", Type: domain.AttributeTypeString}},
+			})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				if attributeRepo.replaced != "" {
+					t.Fatalf("schema stored for %q despite error", attributeRepo.replaced)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if attributeRepo.replaced != tt.wantSlug {
+				t.Fatalf("stored under %q, want %q", attributeRepo.replaced, tt.wantSlug)
+			}
+			if attributes[0].Category != tt.wantSlug {
+				t.Fatalf("definition category = %q, want %q", attributes[0].Category, tt.wantSlug)
+			}
+		})
+	}
+}
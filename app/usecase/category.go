@@ -0,0 +1,104 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"product-service/app/domain"
+	"product-service/config"
+	"regexp"
+	"strings"
+)
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+type categoryUsecase struct {
+	categoryRepo domain.CategoryRepository
+	cfg          *config.Config
+}
+
+func NewCategoryUsecase(categoryRepo domain.CategoryRepository, cfg *config.Config) domain.CategoryUsecase {
+	return &categoryUsecase{categoryRepo, cfg}
+}
+
+func (u *categoryUsecase) GetTree(ctx context.Context) ([]*domain.Category, error) {
+	categories, err := u.categoryRepo.GetAll(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "[categoryUsecase] GetTree", "repository", err)
+		return nil, err
+	}
+
+	byID := make(map[int64]*domain.Category, len(categories))
+	for _, category := range categories {
+		byID[category.ID] = category
+	}
+
+	roots := []*domain.Category{}
+	for _, category := range categories {
+		if category.ParentID == nil {
+			roots = append(roots, category)
+			continue
+		}
+		parent, ok := byID[*category.ParentID]
+		if !ok {
+			slog.WarnContext(ctx, "[categoryUsecase] GetTree", "orphan category", category.ID)
+			continue
+		}
+		parent.Children = append(parent.Children, category)
+	}
+
+	return roots, nil
+}
+
+func (u *categoryUsecase) Create(ctx context.Context, req *domain.CreateCategoryRequest) (*domain.Category, error) {
+	if req.ParentID != nil {
+		if _, err := u.categoryRepo.GetByID(ctx, *req.ParentID); err != nil {
+			slog.ErrorContext(ctx, "[categoryUsecase] Create", "parent", err)
+			if err == domain.ErrNotFound {
+				return nil, fmt.Errorf("%w: parent category %d does not exist", domain.ErrValidation, *req.ParentID)
+			}
+			return nil, err
+		}
+	}
+
+	slug := req.Slug
+	if slug == "" {
+		slug = req.Name
+	}
+	slug = slugify(slug)
+	if slug == "" {
+		return nil, fmt.Errorf("%w: category slug is empty", domain.ErrValidation)
+	}
+
+	category := &domain.Category{
+		ParentID: req.ParentID,
+		Name:     req.Name,
+		Slug:     slug,
+	}
+	if err := u.categoryRepo.Create(ctx, category); err != nil {
+		slog.ErrorContext(ctx, "[categoryUsecase] Create", "repository", err)
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "[categoryUsecase] success Create", "category_id", category.ID, "slug", category.Slug)
+	return category, nil
+}
+
+// resolveCategory loads the category a product is assigned to, reporting an
+// unknown ID as a validation error.
+func resolveCategory(ctx context.Context, categoryRepo domain.CategoryRepository, id int64) (*domain.Category, error) {
+	category, err := categoryRepo.GetByID(ctx, id)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			return nil, fmt.Errorf("%w: category %d does not exist", domain.ErrValidation, id)
+		}
+		return nil, err
+	}
+	return category, nil
+}
+
+// slugify must stay in line with the mapping done by the category taxonomy
+// migration.
+func slugify(s string) string {
+	return strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(strings.TrimSpace(s)), "-"), "-")
+}
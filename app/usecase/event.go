@@ -0,0 +1,73 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"product-service/app/domain"
+	"product-service/pkg/ctxutil"
+	"time"
+)
+
+func newProductEvent(eventType string, product *domain.Product) domain.ProductEvent {
+	return domain.ProductEvent{
+		ID:            fmt.Sprintf("%s:%d:%d", eventType, product.ID, product.Version),
+		Type:          eventType,
+		SchemaVersion: domain.ProductEventSchemaVersion,
+		OccurredAt:    time.Now().UTC(),
+		ProductID:     product.ID,
+		ShopID:        product.ShopID,
+		Version:       product.Version,
+		Product: &domain.ProductEventSnapshot{
+			Name:        product.Name,
+			Description: product.Description,
+			Price:       product.Price,
+			CategoryID:  product.CategoryID,
+			Category:    product.Category,
+			ImageURL:    product.ImageURL,
+			Attributes:  product.Attributes,
+			Active:      product.Active,
+			CreatedAt:   product.CreatedAt,
+			UpdatedAt:   product.UpdatedAt,
+		},
+	}
+}
+
+// productUpdateEvents lists the events of an update: always product.updated,
+// plus price_changed and activated/deactivated when those fields moved.
+func productUpdateEvents(product *domain.Product, previousPrice int64, previousActive bool) []domain.ProductEvent {
+	events := []domain.ProductEvent{newProductEvent(domain.ProductEventUpdated, product)}
+	if product.Price != previousPrice {
+		event := newProductEvent(domain.ProductEventPriceChanged, product)
+		event.PreviousPrice = &previousPrice
+		events = append(events, event)
+	}
+	if product.Active != previousActive {
+		events = append(events, newProductEvent(activeEventType(product.Active), product))
+	}
+	return events
+}
+
+func activeEventType(active bool) string {
+	if active {
+		return domain.ProductEventActivated
+	}
+	return domain.ProductEventDeactivated
+}
+
+// enqueueProductEvents records events in the outbox within the transaction
+// carried by ctx, so they are published if and only if the change commits.
+func enqueueProductEvents(ctx context.Context, outboxRepo domain.OutboxRepository, events ...domain.ProductEvent) error {
+	requestID := ctxutil.GetRequestID(ctx)
+	for _, event := range events {
+		err := outboxRepo.Enqueue(ctx, domain.OutboxTopicProductEvent, domain.ProductEventMessage{
+			RequestID: requestID,
+			Event:     event,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "[enqueueProductEvents] Enqueue", "eventID", event.ID, "error", err)
+			return err
+		}
+	}
+	return nil
+}
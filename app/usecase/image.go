@@ -0,0 +1,192 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"product-service/app/domain"
+	"product-service/config"
+	"strings"
+)
+
+const maxProductImages = 10
+
+type imageUsecase struct {
+	productWriteRepo domain.ProductWriteRepository
+	imageRepo        domain.ImageRepository
+	productCache     domain.ProductCacheInvalidator
+	outboxRepo       domain.OutboxRepository
+	txManager        domain.TransactionManager
+	cfg              *config.Config
+}
+
+func NewImageUsecase(productWriteRepo domain.ProductWriteRepository, imageRepo domain.ImageRepository, productCache domain.ProductCacheInvalidator, outboxRepo domain.OutboxRepository, txManager domain.TransactionManager, cfg *config.Config) domain.ImageUsecase {
+	return &imageUsecase{productWriteRepo, imageRepo, productCache, outboxRepo, txManager, cfg}
+}
+
+func (u *imageUsecase) GetByProductID(ctx context.Context, productID int64) ([]*domain.ProductImage, error) {
+	images, err := u.imageRepo.GetByProductID(ctx, productID)
+	if err != nil {
+		slog.ErrorContext(ctx, "[imageUsecase] GetByProductID", "repository", err)
+		return nil, err
+	}
+	if images == nil {
+		images = []*domain.ProductImage{}
+	}
+
+	return images, nil
+}
+
+func (u *imageUsecase) Add(ctx context.Context, productID int64, req *domain.AddImageRequest) (*domain.ProductImage, error) {
+	product, err := loadOwnedProduct(ctx, u.productWriteRepo, productID)
+	if err != nil {
+		slog.ErrorContext(ctx, "[imageUsecase] Add", "loadOwnedProduct", err)
+		return nil, err
+	}
+
+	if err := validateImageURL(u.cfg, req.URL); err != nil {
+		slog.ErrorContext(ctx, "[imageUsecase] Add", "validateImageURL", err)
+		return nil, err
+	}
+
+	image := &domain.ProductImage{
+		ProductID: productID,
+		URL:       req.URL,
+		AltText:   req.AltText,
+		Primary:   req.Primary,
+	}
+	err = u.changeGallery(ctx, product, func(ctx context.Context) error {
+		// counted under the product lock, so concurrent adds cannot exceed the limit
+		images, err := u.imageRepo.GetByProductID(ctx, productID)
+		if err != nil {
+			slog.ErrorContext(ctx, "[imageUsecase] Add", "GetByProductID", err)
+			return err
+		}
+		if len(images) >= maxProductImages {
+			return fmt.Errorf("%w: a product can have at most %d images", domain.ErrValidation, maxProductImages)
+		}
+		return u.imageRepo.Create(ctx, image)
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "[imageUsecase] Add", "Create", err)
+		return nil, err
+	}
+
+	invalidateProduct(ctx, u.productCache, productID)
+	slog.InfoContext(ctx, "[imageUsecase] success Add", "product_id", productID, "image_id", image.ID)
+	return image, nil
+}
+
+func (u *imageUsecase) SetPrimary(ctx context.Context, productID, id int64) ([]*domain.ProductImage, error) {
+	product, err := loadOwnedProduct(ctx, u.productWriteRepo, productID)
+	if err != nil {
+		slog.ErrorContext(ctx, "[imageUsecase] SetPrimary", "loadOwnedProduct", err)
+		return nil, err
+	}
+
+	err = u.changeGallery(ctx, product, func(ctx context.Context) error {
+		return u.imageRepo.SetPrimary(ctx, productID, id)
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "[imageUsecase] SetPrimary", "repository", err)
+		return nil, err
+	}
+
+	invalidateProduct(ctx, u.productCache, productID)
+	slog.InfoContext(ctx, "[imageUsecase] success SetPrimary", "product_id", productID, "image_id", id)
+	return u.GetByProductID(ctx, productID)
+}
+
+func (u *imageUsecase) Reorder(ctx context.Context, productID int64, req *domain.ReorderImagesRequest) ([]*domain.ProductImage, error) {
+	product, err := loadOwnedProduct(ctx, u.productWriteRepo, productID)
+	if err != nil {
+		slog.ErrorContext(ctx, "[imageUsecase] Reorder", "loadOwnedProduct", err)
+		return nil, err
+	}
+
+	err = u.changeGallery(ctx, product, func(ctx context.Context) error {
+		return u.imageRepo.Reorder(ctx, productID, req.ImageIDs)
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "[imageUsecase] Reorder", "repository", err)
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "[imageUsecase] success Reorder", "product_id", productID)
+	return u.GetByProductID(ctx, productID)
+}
+
+func (u *imageUsecase) Delete(ctx context.Context, productID, id int64) ([]*domain.ProductImage, error) {
+	product, err := loadOwnedProduct(ctx, u.productWriteRepo, productID)
+	if err != nil {
+		slog.ErrorContext(ctx, "[imageUsecase] Delete", "loadOwnedProduct", err)
+		return nil, err
+	}
+
+	err = u.changeGallery(ctx, product, func(ctx context.Context) error {
+		return u.imageRepo.Delete(ctx, productID, id)
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "[imageUsecase] Delete", "repository", err)
+		return nil, err
+	}
+
+	invalidateProduct(ctx, u.productCache, productID)
+	slog.InfoContext(ctx, "[imageUsecase] success Delete", "product_id", productID, "image_id", id)
+	return u.GetByProductID(ctx, productID)
+}
+
+// changeGallery applies a gallery change in a transaction that holds the
+// product lock from the start, so checks made by change see the gallery as it
+// is written. When the change moved another image onto products.image_url,
+// and so bumped the product version, product.updated is recorded in the same
+// transaction.
+func (u *imageUsecase) changeGallery(ctx context.Context, before *domain.Product, change func(ctx context.Context) error) error {
+	return u.txManager.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := u.productWriteRepo.Lock(ctx, before.ID); err != nil {
+			slog.ErrorContext(ctx, "[imageUsecase] changeGallery", "Lock", err)
+			return err
+		}
+
+		if err := change(ctx); err != nil {
+			return err
+		}
+
+		after, err := u.productWriteRepo.GetByID(ctx, before.ID)
+		if err != nil {
+			slog.ErrorContext(ctx, "[imageUsecase] changeGallery", "GetByID", err)
+			return err
+		}
+		if after.Version == before.Version {
+			return nil
+		}
+		return enqueueProductEvents(ctx, u.outboxRepo, newProductEvent(domain.ProductEventUpdated, after))
+	})
+}
+
+// validateImageURL only accepts absolute http(s) URLs and, when an allowlist
+// is configured, only hosts on it.
+func validateImageURL(cfg *config.Config, raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Hostname() == "" {
+		return fmt.Errorf("%w: invalid image url %q", domain.ErrValidation, raw)
+	}
+
+	if cfg.Image.AllowedHosts == "" {
+		return nil
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	for _, allowed := range strings.Split(cfg.Image.AllowedHosts, ",") {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if allowed == "" {
+			continue
+		}
+		if host == allowed || (strings.HasPrefix(allowed, ".") && strings.HasSuffix(host, allowed)) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: image host %q is not allowed", domain.ErrValidation, host)
+}
@@ -0,0 +1,130 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"product-service/app/domain"
+	"product-service/config"
+	"product-service/pkg/ctxutil"
+	"testing"
+)
+
+type passthroughTxManager struct{}
+
+func (passthroughTxManager) WithTransaction(ctx context.Context, fn func(ctx context.Context) error, opts ...domain.TxOption) error {
+	return fn(ctx)
+}
+
+type fakeProductWriteRepository struct {
+	domain.ProductWriteRepository
+	product *domain.Product
+	locked  bool
+}
+
+func (r *fakeProductWriteRepository) Lock(ctx context.Context, id int64) error {
+	if r.product == nil || r.product.ID != id {
+		return domain.ErrNotFound
+	}
+	r.locked = true
+	return nil
+}
+
+func (r *fakeProductWriteRepository) GetByID(ctx context.Context, id int64) (*domain.Product, error) {
+	if r.product == nil || r.product.ID != id {
+		return nil, domain.ErrNotFound
+	}
+	product := *r.product
+	return &product, nil
+}
+
+func (r *fakeProductWriteRepository) BumpVersion(ctx context.Context, id int64) (int64, error) {
+	if r.product == nil || r.product.ID != id {
+		return 0, domain.ErrNotFound
+	}
+	r.product.Version++
+	return r.product.Version, nil
+}
+
+type recordingOutboxRepository struct {
+	domain.OutboxRepository
+	messages []any
+}
+
+func (r *recordingOutboxRepository) Enqueue(ctx context.Context, topic string, payload any) error {
+	r.messages = append(r.messages, payload)
+	return nil
+}
+
+func TestChangeGalleryEmitsProductUpdatedWhenImageMoves(t *testing.T) {
+	tests := []struct {
+		name       string
+		change     func(product *domain.Product)
+		wantEvents int
+	}{
+		{name: "order only", change: func(product *domain.Product) {}},
+		{name: "primary image moved", change: func(product *domain.Product) {
+			product.ImageURL = "https://cdn.example.com/b.jpg"
+			product.Version++
+		}, wantEvents: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := &domain.Product{ID: 1, ShopID: 2, ImageURL: "https://cdn.example.com/a.jpg", Version: 3}
+			productRepo := &fakeProductWriteRepository{product: before}
+			outboxRepo := &recordingOutboxRepository{}
+			u := &imageUsecase{productWriteRepo: productRepo, outboxRepo: outboxRepo, txManager: passthroughTxManager{}}
+
+			err := u.changeGallery(context.Background(), before, func(ctx context.Context) error {
+				after := *before
+				tt.change(&after)
+				productRepo.product = &after
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("changeGallery: %v", err)
+			}
+
+			if len(outboxRepo.messages) != tt.wantEvents {
+				t.Fatalf("enqueued %d messages, want %d", len(outboxRepo.messages), tt.wantEvents)
+			}
+			if tt.wantEvents == 0 {
+				return
+			}
+			event := outboxRepo.messages[0].(domain.ProductEventMessage).Event
+			if event.Type != domain.ProductEventUpdated || event.Version != 4 || event.Product.ImageURL != "https://cdn.example.com/b.jpg" {
+				t.Fatalf("event = %+v, want product.updated at version 4 with the new image", event)
+			}
+		})
+	}
+}
+
+// fullGalleryRepository holds maxProductImages images and records whether the
+// product was locked when they were counted.
+type fullGalleryRepository struct {
+	domain.ImageRepository
+	productRepo     *fakeProductWriteRepository
+	countedUnlocked bool
+}
+
+func (r *fullGalleryRepository) GetByProductID(ctx context.Context, productID int64) ([]*domain.ProductImage, error) {
+	if !r.productRepo.locked {
+		r.countedUnlocked = true
+	}
+	return make([]*domain.ProductImage, maxProductImages), nil
+}
+
+func TestAddImageCountsUnderProductLock(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ctxutil.ShopIDKey, int64(2))
+	productRepo := &fakeProductWriteRepository{product: &domain.Product{ID: 1, ShopID: 2, Version: 3}}
+	imageRepo := &fullGalleryRepository{productRepo: productRepo}
+	u := &imageUsecase{productWriteRepo: productRepo, imageRepo: imageRepo, txManager: passthroughTxManager{}, cfg: &config.Config{}}
+
+	_, err := u.Add(ctx, 1, &domain.AddImageRequest{URL: "https://cdn.example.com/c.jpg"})
+	if !errors.Is(err, domain.ErrValidation) {
+		t.Fatalf("err = %v, want %v", err, domain.ErrValidation)
+	}
+	if imageRepo.countedUnlocked {
+		t.Fatal("images were counted before the product was locked")
+	}
+}
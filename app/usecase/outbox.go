@@ -0,0 +1,212 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"product-service/app/domain"
+	"product-service/config"
+	"time"
+)
+
+const (
+	defaultOutboxPollInterval = time.Second
+	defaultOutboxBatchSize    = 50
+	defaultOutboxMaxAttempts  = 10
+	defaultOutboxRetention    = 7 * 24 * time.Hour
+	outboxLease               = 30 * time.Second
+	outboxMaxBackoff          = 5 * time.Minute
+	outboxSweepInterval       = time.Hour
+)
+
+type outboxHandler func(ctx context.Context, message *domain.OutboxMessage) error
+
+type outboxRelay struct {
+	outboxRepo   domain.OutboxRepository
+	handlers     map[string]outboxHandler
+	pollInterval time.Duration
+	batchSize    int
+	maxAttempts  int
+	retention    time.Duration
+	lastSweep    time.Time
+}
+
+func NewOutboxRelay(outboxRepo domain.OutboxRepository, stockRepo domain.StockRepository, eventPublisher domain.ProductEventPublisher, cfg *config.Config) domain.OutboxRelay {
+	r := &outboxRelay{
+		outboxRepo:   outboxRepo,
+		pollInterval: outboxPollInterval(cfg),
+		batchSize:    cfg.Outbox.BatchSize,
+		maxAttempts:  outboxMaxAttempts(cfg),
+		retention:    time.Duration(cfg.Outbox.RetentionDays) * 24 * time.Hour,
+	}
+	if r.batchSize <= 0 {
+		r.batchSize = defaultOutboxBatchSize
+	}
+	if r.retention <= 0 {
+		r.retention = defaultOutboxRetention
+	}
+
+	r.handlers = map[string]outboxHandler{
+		// Redeliveries of a message reuse its ID as the idempotency key, so a
+		// retry after a lost response does not create the stock twice.
+		domain.OutboxTopicInitStock: func(ctx context.Context, message *domain.OutboxMessage) error {
+			var req domain.InitStockRequest
+			if err := json.Unmarshal(message.Payload, &req); err != nil {
+				return err
+			}
+			return stockRepo.InitStockToWarehouse(ctx, req, outboxIdempotencyKey(message))
+		},
+		domain.OutboxTopicProductEvent: func(ctx context.Context, message *domain.OutboxMessage) error {
+			var msg domain.ProductEventMessage
+			if err := json.Unmarshal(message.Payload, &msg); err != nil {
+				return err
+			}
+			return eventPublisher.Publish(ctx, msg.Event, msg.RequestID)
+		},
+	}
+
+	return r
+}
+
+// Run delivers due outbox messages until ctx is cancelled. Several replicas
+// may run it at once; claimed messages are leased to a single relay. Once an
+// hour it also deletes the messages finished longer ago than the retention.
+func (r *outboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	slog.InfoContext(ctx, "[outboxRelay] started", "pollInterval", r.pollInterval, "batchSize", r.batchSize, "retention", r.retention)
+	for {
+		select {
+		case <-ctx.Done():
+			slog.InfoContext(ctx, "[outboxRelay] stopped")
+			return
+		case <-ticker.C:
+			r.drain(ctx)
+			r.sweep(ctx, time.Now())
+		}
+	}
+}
+
+// drain delivers batches until no due message is left.
+func (r *outboxRelay) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		messages, err := r.outboxRepo.Claim(ctx, r.batchSize, outboxLease)
+		if err != nil {
+			slog.ErrorContext(ctx, "[outboxRelay] Claim", "error", err)
+			return
+		}
+
+		for _, message := range messages {
+			r.deliver(ctx, message)
+		}
+		if len(messages) < r.batchSize {
+			return
+		}
+	}
+}
+
+// sweep deletes finished messages in batches, at most once per
+// outboxSweepInterval.
+func (r *outboxRelay) sweep(ctx context.Context, now time.Time) {
+	if now.Sub(r.lastSweep) < outboxSweepInterval {
+		return
+	}
+	r.lastSweep = now
+
+	before := now.Add(-r.retention)
+	var total int64
+	for ctx.Err() == nil {
+		deleted, err := r.outboxRepo.DeleteFinished(ctx, before, r.batchSize)
+		if err != nil {
+			slog.ErrorContext(ctx, "[outboxRelay] DeleteFinished", "error", err)
+			return
+		}
+		total += deleted
+		if deleted < int64(r.batchSize) {
+			break
+		}
+	}
+	if total > 0 {
+		slog.InfoContext(ctx, "[outboxRelay] deleted finished messages", "count", total, "before", before)
+	}
+}
+
+func (r *outboxRelay) deliver(ctx context.Context, message *domain.OutboxMessage) {
+	handler, ok := r.handlers[message.Topic]
+	if !ok {
+		err := fmt.Errorf("no handler for topic %q", message.Topic)
+		slog.ErrorContext(ctx, "[outboxRelay] deliver", "id", message.ID, "error", err)
+		r.markDead(ctx, message, err)
+		return
+	}
+
+	err := handler(ctx, message)
+	if err == nil {
+		if err := r.outboxRepo.MarkDelivered(ctx, message.ID); err != nil {
+			// the lease runs out and the message is delivered again
+			slog.ErrorContext(ctx, "[outboxRelay] MarkDelivered", "id", message.ID, "error", err)
+		}
+		return
+	}
+
+	if message.Attempts >= r.maxAttempts {
+		slog.ErrorContext(ctx, "[outboxRelay] giving up on message", "id", message.ID, "topic", message.Topic, "attempts", message.Attempts, "error", err)
+		r.markDead(ctx, message, err)
+		return
+	}
+
+	// exponential backoff with jitter, capped
+	backoff := outboxBackoff(message.Attempts)
+	backoff = backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+
+	slog.WarnContext(ctx, "[outboxRelay] delivery failed", "id", message.ID, "topic", message.Topic, "attempts", message.Attempts, "retryIn", backoff, "error", err)
+	if err := r.outboxRepo.MarkFailed(ctx, message.ID, time.Now().Add(backoff), err); err != nil {
+		slog.ErrorContext(ctx, "[outboxRelay] MarkFailed", "id", message.ID, "error", err)
+	}
+}
+
+func (r *outboxRelay) markDead(ctx context.Context, message *domain.OutboxMessage, cause error) {
+	if err := r.outboxRepo.MarkDead(ctx, message.ID, cause); err != nil {
+		// the lease runs out and the message is claimed and given up on again
+		slog.ErrorContext(ctx, "[outboxRelay] MarkDead", "id", message.ID, "error", err)
+	}
+}
+
+// OutboxRetrySpan is the longest time between the first and the last
+// delivery attempt of an outbox message. A publish whose ack was lost is
+// retried within it, so deduplication windows downstream must cover it.
+func OutboxRetrySpan(cfg *config.Config) time.Duration {
+	var span time.Duration
+	for attempts := 1; attempts < outboxMaxAttempts(cfg); attempts++ {
+		// a message whose failure was not recorded waits for its lease
+		span += max(outboxBackoff(attempts), outboxLease) + outboxPollInterval(cfg)
+	}
+	return span
+}
+
+// outboxBackoff is the delay before retrying a message that failed its
+// attempts-th delivery, before jitter.
+func outboxBackoff(attempts int) time.Duration {
+	return min(time.Second<<min(attempts, 16), outboxMaxBackoff)
+}
+
+func outboxPollInterval(cfg *config.Config) time.Duration {
+	if cfg.Outbox.PollIntervalMs <= 0 {
+		return defaultOutboxPollInterval
+	}
+	return time.Duration(cfg.Outbox.PollIntervalMs) * time.Millisecond
+}
+
+func outboxMaxAttempts(cfg *config.Config) int {
+	if cfg.Outbox.MaxAttempts <= 0 {
+		return defaultOutboxMaxAttempts
+	}
+	return cfg.Outbox.MaxAttempts
+}
+
+func outboxIdempotencyKey(message *domain.OutboxMessage) string {
+	return fmt.Sprintf("product-service-outbox-%d", message.ID)
+}
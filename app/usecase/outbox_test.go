@@ -0,0 +1,192 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"product-service/app/domain"
+	"product-service/config"
+	"testing"
+	"time"
+)
+
+func TestOutboxRetrySpanCoversEveryRetry(t *testing.T) {
+	cfg := &config.Config{}
+
+	// every failed attempt but the last is retried after at most the capped
+	// backoff, or the lease when the failure could not be recorded
+	var longest time.Duration
+	for attempts := 1; attempts < defaultOutboxMaxAttempts; attempts++ {
+		longest += max(outboxBackoff(attempts), outboxLease)
+	}
+
+	if span := OutboxRetrySpan(cfg); span < longest {
+		t.Fatalf("OutboxRetrySpan = %s, shorter than the retries it must cover (%s)", span, longest)
+	}
+	if outboxBackoff(30) != outboxMaxBackoff {
+		t.Fatalf("outboxBackoff(30) = %s, want the cap %s", outboxBackoff(30), outboxMaxBackoff)
+	}
+}
+
+// fakeOutboxRepository hands out the pending messages in batches and records
+// what the relay did with them.
+type fakeOutboxRepository struct {
+	domain.OutboxRepository
+	pending   []*domain.OutboxMessage
+	delivered []int64
+	failed    map[int64]time.Time
+	dead      map[int64]error
+	markErr   error
+
+	finished     int
+	deleteBefore []time.Time
+}
+
+func (r *fakeOutboxRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]*domain.OutboxMessage, error) {
+	n := min(limit, len(r.pending))
+	claimed := r.pending[:n]
+	r.pending = r.pending[n:]
+	return claimed, nil
+}
+
+func (r *fakeOutboxRepository) MarkDelivered(ctx context.Context, id int64) error {
+	r.delivered = append(r.delivered, id)
+	return nil
+}
+
+func (r *fakeOutboxRepository) MarkFailed(ctx context.Context, id int64, retryAt time.Time, cause error) error {
+	r.failed[id] = retryAt
+	return nil
+}
+
+func (r *fakeOutboxRepository) MarkDead(ctx context.Context, id int64, cause error) error {
+	if r.markErr != nil {
+		return r.markErr
+	}
+	r.dead[id] = cause
+	return nil
+}
+
+func (r *fakeOutboxRepository) DeleteFinished(ctx context.Context, before time.Time, limit int) (int64, error) {
+	r.deleteBefore = append(r.deleteBefore, before)
+	deleted := min(limit, r.finished)
+	r.finished -= deleted
+	return int64(deleted), nil
+}
+
+func newTestOutboxRelay(repo *fakeOutboxRepository) *outboxRelay {
+	failing := errors.New("warehouse down")
+	return &outboxRelay{
+		outboxRepo: repo,
+		handlers: map[string]outboxHandler{
+			"ok":   func(ctx context.Context, message *domain.OutboxMessage) error { return nil },
+			"fail": func(ctx context.Context, message *domain.OutboxMessage) error { return failing },
+		},
+		batchSize:   2,
+		maxAttempts: 3,
+		retention:   24 * time.Hour,
+	}
+}
+
+func TestOutboxRelayDeliver(t *testing.T) {
+	repo := &fakeOutboxRepository{
+		pending: []*domain.OutboxMessage{
+			{ID: 1, Topic: "ok", Attempts: 1},
+			{ID: 2, Topic: "fail", Attempts: 2},
+			{ID: 3, Topic: "fail", Attempts: 3},
+			{ID: 4, Topic: "unknown", Attempts: 1},
+		},
+		failed: map[int64]time.Time{},
+		dead:   map[int64]error{},
+	}
+	relay := newTestOutboxRelay(repo)
+
+	start := time.Now()
+	relay.drain(context.Background())
+
+	if len(repo.pending) != 0 {
+		t.Fatalf("%d messages left pending, want every batch drained", len(repo.pending))
+	}
+	if len(repo.delivered) != 1 || repo.delivered[0] != 1 {
+		t.Fatalf("delivered = %v, want [1]", repo.delivered)
+	}
+
+	// the second failure waits between half and all of the 4s backoff, give
+	// or take the time the test takes
+	retryAt, ok := repo.failed[2]
+	if !ok {
+		t.Fatalf("failed = %v, want message 2 to be retried", repo.failed)
+	}
+	if wait := retryAt.Sub(start); wait < 2*time.Second || wait > 5*time.Second {
+		t.Fatalf("message 2 is retried in %s, want 2s to 4s", wait)
+	}
+
+	if len(repo.failed) != 1 {
+		t.Fatalf("failed = %v, want only message 2", repo.failed)
+	}
+	if _, ok := repo.dead[3]; !ok {
+		t.Fatalf("dead = %v, want message 3 out of attempts", repo.dead)
+	}
+	if _, ok := repo.dead[4]; !ok {
+		t.Fatalf("dead = %v, want message 4 without a handler", repo.dead)
+	}
+}
+
+func TestOutboxRelayKeepsGoingWhenMarkDeadFails(t *testing.T) {
+	repo := &fakeOutboxRepository{
+		pending: []*domain.OutboxMessage{
+			{ID: 1, Topic: "unknown", Attempts: 1},
+			{ID: 2, Topic: "ok", Attempts: 1},
+		},
+		dead:    map[int64]error{},
+		markErr: errors.New("connection reset"),
+	}
+	relay := newTestOutboxRelay(repo)
+
+	relay.drain(context.Background())
+
+	if len(repo.delivered) != 1 || repo.delivered[0] != 2 {
+		t.Fatalf("delivered = %v, want [2]", repo.delivered)
+	}
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: 2 * time.Second},
+		{attempts: 3, want: 8 * time.Second},
+		{attempts: 8, want: 256 * time.Second},
+		{attempts: 9, want: outboxMaxBackoff},
+		{attempts: 100, want: outboxMaxBackoff},
+	}
+	for _, tt := range tests {
+		if got := outboxBackoff(tt.attempts); got != tt.want {
+			t.Errorf("outboxBackoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestOutboxRelaySweep(t *testing.T) {
+	repo := &fakeOutboxRepository{finished: 5}
+	relay := newTestOutboxRelay(repo)
+	now := time.Now()
+
+	relay.sweep(context.Background(), now)
+	if repo.finished != 0 {
+		t.Fatalf("%d finished messages left, want all deleted in batches", repo.finished)
+	}
+	if len(repo.deleteBefore) != 3 || !repo.deleteBefore[0].Equal(now.Add(-24*time.Hour)) {
+		t.Fatalf("DeleteFinished calls = %v, want 3 batches before %s", repo.deleteBefore, now.Add(-24*time.Hour))
+	}
+
+	// the next sweep waits for outboxSweepInterval
+	relay.sweep(context.Background(), now.Add(time.Minute))
+	if len(repo.deleteBefore) != 3 {
+		t.Fatalf("swept again after a minute")
+	}
+	relay.sweep(context.Background(), now.Add(outboxSweepInterval))
+	if len(repo.deleteBefore) != 4 {
+		t.Fatalf("did not sweep again after %s", outboxSweepInterval)
+	}
+}
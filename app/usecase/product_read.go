@@ -2,19 +2,46 @@ package usecase
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"product-service/app/domain"
 	"product-service/config"
+	"product-service/pkg"
+	"slices"
+	"strconv"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultStockLockTTL   = 3 * time.Second
+	stockLockPollInterval = 50 * time.Millisecond
 )
 
 type productReadUsecase struct {
 	productReadRepo domain.ProductReadRepository
+	variantRepo     domain.VariantRepository
+	imageRepo       domain.ImageRepository
 	warehouseRepo   domain.StockRepository
 	cfg             *config.Config
+	stockGroup      singleflight.Group
+	stockLockTTL    time.Duration
 }
 
-func NewProductReadUsecase(productReadRepo domain.ProductReadRepository, warehouseRepo domain.StockRepository, cfg *config.Config) domain.ProductReadUsecase {
-	return &productReadUsecase{productReadRepo, warehouseRepo, cfg}
+func NewProductReadUsecase(productReadRepo domain.ProductReadRepository, variantRepo domain.VariantRepository, imageRepo domain.ImageRepository, warehouseRepo domain.StockRepository, cfg *config.Config) domain.ProductReadUsecase {
+	stockLockTTL := time.Duration(cfg.Cache.StockLockTTLMs) * time.Millisecond
+	if stockLockTTL <= 0 {
+		stockLockTTL = defaultStockLockTTL
+	}
+	return &productReadUsecase{
+		productReadRepo: productReadRepo,
+		variantRepo:     variantRepo,
+		imageRepo:       imageRepo,
+		warehouseRepo:   warehouseRepo,
+		cfg:             cfg,
+		stockLockTTL:    stockLockTTL,
+	}
 }
 
 func (u *productReadUsecase) GetByID(ctx context.Context, id int64) (*domain.ProductResponse, error) {
@@ -28,45 +55,372 @@ func (u *productReadUsecase) GetByID(ctx context.Context, id int64) (*domain.Pro
 		return nil, domain.ErrNotFound
 	}
 
-	stock, err := u.warehouseRepo.GetStock(ctx, product.ID)
+	images, err := u.imageRepo.GetByProductID(ctx, product.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "[productReadUsecase] GetImages", "error", err)
+		return nil, err
+	}
+
+	variants, err := u.variantRepo.GetByProductID(ctx, product.ID)
 	if err != nil {
-		slog.WarnContext(ctx, "[productReadUsecase] GetStock", "error", err)
+		slog.ErrorContext(ctx, "[productReadUsecase] GetByProductID", "error", err)
+		return nil, err
+	}
 
-		stock, err = u.warehouseRepo.FetchStockFromService(ctx, product.ID)
+	var stock int
+	var status string
+	var variantResponses []*domain.VariantResponse
+	if len(variants) > 0 {
+		// Stock is kept per variant, the product stock is their sum
+		variantResponses, err = variantsWithStock(ctx, u.warehouseRepo, product, variants)
 		if err != nil {
-			slog.ErrorContext(ctx, "[productReadUsecase] FetchStockFromService", "error", err)
 			return nil, err
 		}
-		if err := u.warehouseRepo.CacheStock(ctx, product.ID, stock); err != nil {
-			slog.ErrorContext(ctx, "[productReadUsecase] CacheStock", "error", err)
+		for _, variant := range variantResponses {
+			stock += variant.Stock
+			if variant.StockStatus == domain.StockStatusUnknown {
+				status = domain.StockStatusUnknown
+			}
+		}
+	} else {
+		stock, err = u.getStock(ctx, product.ID)
+		if errors.Is(err, domain.ErrStockUnavailable) {
+			// degrade rather than failing the whole product page
+			slog.WarnContext(ctx, "[productReadUsecase] GetByID stock unavailable", "productID", product.ID, "error", err)
+			status = domain.StockStatusUnknown
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	product.Images = images
+	res := toProductResponse(product, stock)
+	if status != "" {
+		res.StockStatus = status
+	}
+	res.Variants = variantResponses
+	return res, nil
+}
+
+func (u *productReadUsecase) getStock(ctx context.Context, productID int64) (int, error) {
+	stock, err := u.warehouseRepo.GetStock(ctx, productID)
+	if err == nil {
+		return stock, nil
+	}
+	slog.WarnContext(ctx, "[productReadUsecase] GetStock", "error", err)
+
+	// Concurrent misses for the same product share one fetch. The shared call
+	// must not be cancelled when the request that started it goes away, but
+	// each request stops waiting for it when its own context is done.
+	ch := u.stockGroup.DoChan(strconv.FormatInt(productID, 10), func() (any, error) {
+		return u.fetchStock(context.WithoutCancel(ctx), productID)
+	})
+
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return 0, res.Err
+		}
+		if res.Shared {
+			slog.InfoContext(ctx, "[productReadUsecase] getStock shared fetch", "productID", productID)
+		}
+		return res.Val.(int), nil
+	case <-ctx.Done():
+		slog.WarnContext(ctx, "[productReadUsecase] getStock", "productID", productID, "error", ctx.Err())
+		return 0, ctx.Err()
+	}
+}
+
+// fetchStock loads the stock from the warehouse service while holding a
+// Redis lock, so only one replica fetches a product at a time. Replicas that
+// lose the race wait for the winner to fill the cache and fall back to their
+// own fetch if it does not happen in time.
+func (u *productReadUsecase) fetchStock(ctx context.Context, productID int64) (int, error) {
+	token, err := u.warehouseRepo.AcquireStockLock(ctx, productID, u.stockLockTTL)
+	if err != nil {
+		slog.WarnContext(ctx, "[productReadUsecase] AcquireStockLock", "error", err)
+	}
+
+	if err == nil && token == "" {
+		if stock, ok := u.waitForStock(ctx, productID); ok {
+			return stock, nil
+		}
+		slog.WarnContext(ctx, "[productReadUsecase] fetchStock lock wait timed out", "productID", productID)
+	}
+	if token != "" {
+		defer u.warehouseRepo.ReleaseStockLock(ctx, productID, token)
+	}
+
+	stock, err := u.warehouseRepo.FetchStockFromService(ctx, productID)
+	if err != nil {
+		slog.ErrorContext(ctx, "[productReadUsecase] FetchStockFromService", "error", err)
+		return 0, err
+	}
+	if err := u.warehouseRepo.FillStock(ctx, productID, stock); err != nil {
+		slog.ErrorContext(ctx, "[productReadUsecase] FillStock", "error", err)
+		return 0, err
+	}
+
+	return stock, nil
+}
+
+// waitForStock polls the cache until the lock holder has filled it, for at
+// most the lock TTL.
+func (u *productReadUsecase) waitForStock(ctx context.Context, productID int64) (int, bool) {
+	timeout := time.NewTimer(u.stockLockTTL)
+	defer timeout.Stop()
+	ticker := time.NewTicker(stockLockPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return 0, false
+		case <-timeout.C:
+			return 0, false
+		case <-ticker.C:
+			if stock, err := u.warehouseRepo.GetStock(ctx, productID); err == nil {
+				return stock, true
+			}
+		}
+	}
+}
+
+func (u *productReadUsecase) GetListByQuery(ctx context.Context, query domain.ProductQuery) (*domain.ProductListResponse, error) {
+	// fetch one extra product to find out whether another page follows
+	fetch := query
+	fetch.Limit++
+	products, err := u.productReadRepo.GetListByQuery(ctx, fetch)
+	if err != nil {
+		slog.ErrorContext(ctx, "[productReadUsecase] GetListByQuery", "error", err)
+		return nil, err
+	}
+
+	res := &domain.ProductListResponse{Products: []*domain.ProductResponse{}}
+	if len(products) > query.Limit {
+		products = products[:query.Limit]
+		res.HasMore = true
+		res.NextCursor, err = pkg.EncodeCursor(productCursor(query, products[len(products)-1]))
+		if err != nil {
+			slog.ErrorContext(ctx, "[productReadUsecase] GetListByQuery", "EncodeCursor", err)
+			return nil, domain.ErrInternal
+		}
+	}
+
+	if len(products) > 0 {
+		productIDs := make([]int64, 0, len(products))
+		for _, product := range products {
+			productIDs = append(productIDs, product.ID)
+		}
+		images, err := u.imageRepo.GetByProductIDs(ctx, productIDs)
+		if err != nil {
+			slog.ErrorContext(ctx, "[productReadUsecase] GetListByQuery", "GetByProductIDs", err)
+			return nil, err
+		}
+		stocks, err := u.getStocks(ctx, productIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, product := range products {
+			product.Images = images[product.ID]
+			item := toProductResponse(product, stocks[product.ID])
+			if _, ok := stocks[product.ID]; !ok {
+				item.StockStatus = domain.StockStatusUnknown
+			}
+			res.Products = append(res.Products, item)
+		}
+	}
+
+	if query.IncludeTotal {
+		total, err := u.productReadRepo.CountByQuery(ctx, query)
+		if err != nil {
+			slog.ErrorContext(ctx, "[productReadUsecase] GetListByQuery", "CountByQuery", err)
+			return nil, err
+		}
+		res.Total = &total
+	}
+
+	if len(query.Facets) > 0 {
+		res.Facets, err = u.productReadRepo.GetFacets(ctx, query, query.Facets)
+		if err != nil {
+			slog.ErrorContext(ctx, "[productReadUsecase] GetListByQuery", "GetFacets", err)
 			return nil, err
 		}
 	}
 
+	return res, nil
+}
+
+// getStocks reads the stock of a page of products from the cache and fetches
+// every miss from the warehouse service in a single call. Products with
+// variants get the sum of their variant stocks, as on the product page.
+// While the warehouse is unavailable the misses are left out of the result.
+func (u *productReadUsecase) getStocks(ctx context.Context, productIDs []int64) (map[int64]int, error) {
+	variants, err := u.variantRepo.GetByProductIDs(ctx, productIDs)
+	if err != nil {
+		slog.ErrorContext(ctx, "[productReadUsecase] getStocks", "GetByProductIDs", err)
+		return nil, err
+	}
+
+	var plainIDs []int64
+	for _, productID := range productIDs {
+		if len(variants[productID]) == 0 {
+			plainIDs = append(plainIDs, productID)
+		}
+	}
+
+	stocks, err := u.getProductStocks(ctx, plainIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	variantStocks, err := u.getVariantStocks(ctx, variants)
+	if err != nil {
+		return nil, err
+	}
+	for productID, productVariants := range variants {
+		sum, complete := 0, true
+		for _, variant := range productVariants {
+			stock, ok := variantStocks[variant.ID]
+			if !ok {
+				complete = false
+				break
+			}
+			sum += stock
+		}
+		if complete {
+			stocks[productID] = sum
+		}
+	}
+
+	return stocks, nil
+}
+
+func (u *productReadUsecase) getProductStocks(ctx context.Context, productIDs []int64) (map[int64]int, error) {
+	stocks, err := u.warehouseRepo.GetStocks(ctx, productIDs)
+	if err != nil {
+		slog.WarnContext(ctx, "[productReadUsecase] GetStocks", "error", err)
+		stocks = make(map[int64]int, len(productIDs))
+	}
+
+	var misses []int64
+	for _, productID := range productIDs {
+		if _, ok := stocks[productID]; !ok {
+			misses = append(misses, productID)
+		}
+	}
+	if len(misses) == 0 {
+		return stocks, nil
+	}
+
+	fetched, err := u.warehouseRepo.FetchStocksFromService(ctx, misses)
+	if errors.Is(err, domain.ErrStockUnavailable) {
+		slog.WarnContext(ctx, "[productReadUsecase] FetchStocksFromService", "error", err)
+		return stocks, nil
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "[productReadUsecase] FetchStocksFromService", "error", err)
+		return nil, err
+	}
+	if err := u.warehouseRepo.FillStocks(ctx, fetched); err != nil {
+		slog.ErrorContext(ctx, "[productReadUsecase] FillStocks", "error", err)
+		return nil, err
+	}
+	for productID, stock := range fetched {
+		stocks[productID] = stock
+	}
+
+	return stocks, nil
+}
+
+// getVariantStocks reads the stock of the variants of a page from the cache
+// and fetches all misses from the warehouse in one batch call.
+func (u *productReadUsecase) getVariantStocks(ctx context.Context, variants map[int64][]*domain.ProductVariant) (map[int64]int, error) {
+	var variantIDs []int64
+	for _, productVariants := range variants {
+		for _, variant := range productVariants {
+			variantIDs = append(variantIDs, variant.ID)
+		}
+	}
+	slices.Sort(variantIDs)
+
+	stocks, err := u.warehouseRepo.GetVariantStocks(ctx, variantIDs)
+	if err != nil {
+		slog.WarnContext(ctx, "[productReadUsecase] GetVariantStocks", "error", err)
+		stocks = make(map[int64]int, len(variantIDs))
+	}
+
+	var misses []int64
+	for _, variantID := range variantIDs {
+		if _, ok := stocks[variantID]; !ok {
+			misses = append(misses, variantID)
+		}
+	}
+	if len(misses) == 0 {
+		return stocks, nil
+	}
+
+	fetched, err := u.warehouseRepo.FetchVariantStocksFromService(ctx, misses)
+	if errors.Is(err, domain.ErrStockUnavailable) {
+		slog.WarnContext(ctx, "[productReadUsecase] FetchVariantStocksFromService", "error", err)
+		return stocks, nil
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "[productReadUsecase] FetchVariantStocksFromService", "error", err)
+		return nil, err
+	}
+	if err := u.warehouseRepo.FillVariantStocks(ctx, fetched); err != nil {
+		slog.ErrorContext(ctx, "[productReadUsecase] FillVariantStocks", "error", err)
+		return nil, err
+	}
+	for variantID, stock := range fetched {
+		stocks[variantID] = stock
+	}
+
+	return stocks, nil
+}
+
+func toProductResponse(product *domain.Product, stock int) *domain.ProductResponse {
 	return &domain.ProductResponse{
 		ID:          product.ID,
 		Name:        product.Name,
 		Description: product.Description,
 		Price:       product.Price,
+		CategoryID:  product.CategoryID,
 		Category:    product.Category,
 		ImageURL:    product.ImageURL,
 		ShopID:      product.ShopID,
+		Attributes:  product.Attributes,
+		Images:      product.Images,
 		Stock:       stock,
+		StockStatus: stockStatus(stock),
+		Highlight:   product.Highlight,
+		Version:     product.Version,
 		CreatedAt:   product.CreatedAt,
 		UpdatedAt:   product.UpdatedAt,
-	}, nil
+	}
 }
 
-func (u *productReadUsecase) GetListByQuery(ctx context.Context, query domain.ProductQuery) ([]*domain.Product, error) {
-	products, err := u.productReadRepo.GetListByQuery(ctx, query)
-	if err != nil {
-		slog.ErrorContext(ctx, "[productReadUsecase] GetListByQuery", "error", err)
-		return nil, err
-	}
-	if products == nil {
-		slog.ErrorContext(ctx, "[productReadUsecase] GetListByQuery", "error", domain.ErrNotFound)
-		return nil, domain.ErrNotFound
+func stockStatus(stock int) string {
+	if stock > 0 {
+		return domain.StockStatusInStock
 	}
+	return domain.StockStatusOutOfStock
+}
 
-	return products, nil
+// productCursor records the position of the last product of a page in the
+// sort order of query.
+func productCursor(query domain.ProductQuery, last *domain.Product) *domain.ProductCursor {
+	cursor := &domain.ProductCursor{SortBy: query.SortBy, SortOrder: query.SortOrder, ID: last.ID}
+	switch query.SortBy {
+	case "price":
+		cursor.Price = &last.Price
+	case "relevance":
+		if last.Highlight != nil {
+			cursor.Rank = &last.Highlight.Rank
+		}
+	default:
+		cursor.CreatedAt = &last.CreatedAt
+	}
+	return cursor
 }
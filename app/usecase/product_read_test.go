@@ -0,0 +1,183 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"product-service/app/domain"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProductCursorBreaksTiesByID(t *testing.T) {
+	createdAt := time.Date(2025, 3, 1, 10, 30, 0, 0, time.UTC)
+	last := &domain.Product{ID: 9, Price: 150000, CreatedAt: createdAt, Highlight: &domain.SearchHighlight{Rank: 0.25}}
+
+	tests := []struct {
+		sortBy string
+		check  func(*domain.ProductCursor) bool
+	}{
+		{sortBy: "created_at", check: func(c *domain.ProductCursor) bool {
+			return c.CreatedAt != nil && c.CreatedAt.Equal(createdAt) && c.Price == nil && c.Rank == nil
+		}},
+		{sortBy: "price", check: func(c *domain.ProductCursor) bool {
+			return c.Price != nil && *c.Price == 150000 && c.CreatedAt == nil && c.Rank == nil
+		}},
+		{sortBy: "relevance", check: func(c *domain.ProductCursor) bool {
+			return c.Rank != nil && *c.Rank == 0.25 && c.CreatedAt == nil && c.Price == nil
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sortBy, func(t *testing.T) {
+			cursor := productCursor(domain.ProductQuery{SortBy: tt.sortBy, SortOrder: "desc"}, last)
+			if cursor.ID != last.ID {
+				t.Fatalf("cursor ID = %d, want %d", cursor.ID, last.ID)
+			}
+			if cursor.SortBy != tt.sortBy || cursor.SortOrder != "desc" {
+				t.Fatalf("cursor sort = %s %s, want %s desc", cursor.SortBy, cursor.SortOrder, tt.sortBy)
+			}
+			if !tt.check(cursor) {
+				t.Fatalf("cursor %+v does not carry only the %s key", cursor, tt.sortBy)
+			}
+		})
+	}
+}
+
+type fakeVariantRepository struct {
+	domain.VariantRepository
+	variants map[int64][]*domain.ProductVariant
+}
+
+func (r *fakeVariantRepository) GetByProductIDs(ctx context.Context, productIDs []int64) (map[int64][]*domain.ProductVariant, error) {
+	variants := map[int64][]*domain.ProductVariant{}
+	for _, productID := range productIDs {
+		if v, ok := r.variants[productID]; ok {
+			variants[productID] = v
+		}
+	}
+	return variants, nil
+}
+
+// fakeStockRepository keeps the cache in maps and serves warehouse fetches
+// from warehouse, or fails them with ErrStockUnavailable when it is nil.
+type fakeStockRepository struct {
+	domain.StockRepository
+	products         map[int64]int
+	variants         map[int64]int
+	warehouse        map[int64]int
+	warehouseVariant map[int64]int
+
+	variantFetches [][]int64
+}
+
+func (r *fakeStockRepository) GetStocks(ctx context.Context, productIDs []int64) (map[int64]int, error) {
+	return pick(r.products, productIDs), nil
+}
+
+func (r *fakeStockRepository) GetVariantStocks(ctx context.Context, variantIDs []int64) (map[int64]int, error) {
+	return pick(r.variants, variantIDs), nil
+}
+
+func (r *fakeStockRepository) FetchStocksFromService(ctx context.Context, productIDs []int64) (map[int64]int, error) {
+	if r.warehouse == nil {
+		return nil, domain.ErrStockUnavailable
+	}
+	return pick(r.warehouse, productIDs), nil
+}
+
+func (r *fakeStockRepository) FetchVariantStocksFromService(ctx context.Context, variantIDs []int64) (map[int64]int, error) {
+	r.variantFetches = append(r.variantFetches, variantIDs)
+	if r.warehouseVariant == nil {
+		return nil, domain.ErrStockUnavailable
+	}
+	return pick(r.warehouseVariant, variantIDs), nil
+}
+
+func (r *fakeStockRepository) FillStocks(ctx context.Context, stocks map[int64]int) error {
+	for id, stock := range stocks {
+		r.products[id] = stock
+	}
+	return nil
+}
+
+func (r *fakeStockRepository) FillVariantStocks(ctx context.Context, stocks map[int64]int) error {
+	for id, stock := range stocks {
+		r.variants[id] = stock
+	}
+	return nil
+}
+
+func pick(stocks map[int64]int, ids []int64) map[int64]int {
+	picked := map[int64]int{}
+	for _, id := range ids {
+		if stock, ok := stocks[id]; ok {
+			picked[id] = stock
+		}
+	}
+	return picked
+}
+
+func TestGetStocksSumsVariantStock(t *testing.T) {
+	variantRepo := &fakeVariantRepository{variants: map[int64][]*domain.ProductVariant{
+		2: {{ID: 20, ProductID: 2}, {ID: 21, ProductID: 2}},
+		3: {{ID: 30, ProductID: 3}},
+	}}
+	stockRepo := &fakeStockRepository{
+		// a stale product key of a variant product must be ignored
+		products:         map[int64]int{1: 5, 2: 99},
+		variants:         map[int64]int{20: 3},
+		warehouse:        map[int64]int{4: 7},
+		warehouseVariant: map[int64]int{21: 4},
+	}
+	u := &productReadUsecase{variantRepo: variantRepo, warehouseRepo: stockRepo}
+
+	stocks, err := u.getStocks(context.Background(), []int64{1, 2, 3, 4})
+	if err != nil {
+		t.Fatalf("getStocks: %v", err)
+	}
+
+	// product 3 stays unknown: its only variant is neither cached nor
+	// available from the warehouse
+	want := map[int64]int{1: 5, 2: 7, 4: 7}
+	if !reflect.DeepEqual(stocks, want) {
+		t.Fatalf("stocks = %v, want %v", stocks, want)
+	}
+	if stockRepo.variants[21] != 4 {
+		t.Fatalf("fetched variant stock was not cached: %v", stockRepo.variants)
+	}
+	wantFetches := [][]int64{{21, 30}}
+	if !reflect.DeepEqual(stockRepo.variantFetches, wantFetches) {
+		t.Fatalf("variant fetches = %v, want one batch %v", stockRepo.variantFetches, wantFetches)
+	}
+}
+
+// lockedStockRepository behaves as if another replica holds the stock lock
+// and never fills the cache.
+type lockedStockRepository struct {
+	domain.StockRepository
+}
+
+func (r *lockedStockRepository) GetStock(ctx context.Context, productID int64) (int, error) {
+	return 0, errors.New("cache miss")
+}
+
+func (r *lockedStockRepository) AcquireStockLock(ctx context.Context, productID int64, ttl time.Duration) (string, error) {
+	return "", nil
+}
+
+func TestGetStockStopsWaitingWhenRequestIsCancelled(t *testing.T) {
+	u := &productReadUsecase{warehouseRepo: &lockedStockRepository{}, stockLockTTL: time.Minute}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := u.getStock(ctx, 1)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("getStock returned after %s, want about the request deadline", elapsed)
+	}
+}
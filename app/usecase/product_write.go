@@ -1,52 +1,124 @@
 package usecase
 
 import (
+	"bytes"
 	"context"
-	"database/sql"
+	"encoding/json"
+	"fmt"
 	"log/slog"
 	"product-service/app/domain"
 	"product-service/config"
+	"product-service/pkg"
+	"product-service/pkg/ctxutil"
+	"time"
+
+	"github.com/go-playground/validator/v10"
 )
 
 type productWriteUsecase struct {
 	productReadRepo  domain.ProductReadRepository
 	productWriteRepo domain.ProductWriteRepository
-	stockRepo        domain.StockRepository
+	variantRepo      domain.VariantRepository
+	attributeRepo    domain.AttributeRepository
+	categoryRepo     domain.CategoryRepository
+	imageRepo        domain.ImageRepository
+	outboxRepo       domain.OutboxRepository
+	txManager        domain.TransactionManager
+	productCache     domain.ProductCacheInvalidator
+	validator        *validator.Validate
 	cfg              *config.Config
 }
 
-func NewProductWriteUsecase(productReadRepo domain.ProductReadRepository, productWriteRepo domain.ProductWriteRepository, stockRepo domain.StockRepository, cfg *config.Config) domain.ProductWriteUsecase {
-	return &productWriteUsecase{productReadRepo, productWriteRepo, stockRepo, cfg}
+func NewProductWriteUsecase(productReadRepo domain.ProductReadRepository, productWriteRepo domain.ProductWriteRepository, variantRepo domain.VariantRepository, attributeRepo domain.AttributeRepository, categoryRepo domain.CategoryRepository, imageRepo domain.ImageRepository, outboxRepo domain.OutboxRepository, txManager domain.TransactionManager, productCache domain.ProductCacheInvalidator, validator *validator.Validate, cfg *config.Config) domain.ProductWriteUsecase {
+	return &productWriteUsecase{productReadRepo, productWriteRepo, variantRepo, attributeRepo, categoryRepo, imageRepo, outboxRepo, txManager, productCache, validator, cfg}
 }
 
 func (u *productWriteUsecase) Create(ctx context.Context, shopID int64, req *domain.CreateProductRequest) (*domain.CreateProductResponse, error) {
+	category, err := resolveCategory(ctx, u.categoryRepo, req.CategoryID)
+	if err != nil {
+		slog.ErrorContext(ctx, "[productWriteUsecase] Create", "resolveCategory", err)
+		return nil, err
+	}
+
+	attributes, err := validateAttributes(ctx, u.attributeRepo, category.Slug, req.Attributes)
+	if err != nil {
+		slog.ErrorContext(ctx, "[productWriteUsecase] Create", "validateAttributes", err)
+		return nil, err
+	}
+
+	if err := validateImageURL(u.cfg, req.ImageURL); err != nil {
+		slog.ErrorContext(ctx, "[productWriteUsecase] Create", "validateImageURL", err)
+		return nil, err
+	}
+
 	product := &domain.Product{
 		Name:        req.Name,
 		Description: req.Description,
 		Price:       req.Price,
-		Category:    req.Category,
+		CategoryID:  category.ID,
+		Category:    category.Slug,
 		ImageURL:    req.ImageURL,
 		ShopID:      shopID,
+		Attributes:  attributes,
 		Active:      true,
 	}
 
-	// Use transaction
-	err := u.productWriteRepo.WithTransaction(ctx, func(ctx context.Context, tx *sql.Tx) error {
+	// The warehouse stock is initialised through the outbox, so it happens
+	// if and only if the product is committed.
+	err = u.txManager.WithTransaction(ctx, func(ctx context.Context) error {
 		// Create product
 		if err := u.productWriteRepo.Create(ctx, product); err != nil {
 			slog.ErrorContext(ctx, "[productWriteUsecase] Create", "repository", err)
 			return err
 		}
 
-		// init stock
-		err := u.stockRepo.InitStockToWarehouse(ctx, domain.InitStockRequest{
-			ShopID:    product.ShopID,
-			ProductID: product.ID,
-		})
-		if err != nil {
-			slog.ErrorContext(ctx, "[productWriteUsecase] Create", "InitStockToWarehouse", err)
+		// the product image starts the gallery as its primary image
+		if err := u.imageRepo.Create(ctx, &domain.ProductImage{ProductID: product.ID, URL: product.ImageURL, Primary: true}); err != nil {
+			slog.ErrorContext(ctx, "[productWriteUsecase] Create", "imageRepository", err)
 			return err
 		}
+
+		if err := enqueueProductEvents(ctx, u.outboxRepo, newProductEvent(domain.ProductEventCreated, product)); err != nil {
+			return err
+		}
+
+		// products without variants keep their stock at product level
+		if len(req.Variants) == 0 {
+			err := u.outboxRepo.Enqueue(ctx, domain.OutboxTopicInitStock, domain.InitStockRequest{
+				ShopID:    product.ShopID,
+				ProductID: product.ID,
+			})
+			if err != nil {
+				slog.ErrorContext(ctx, "[productWriteUsecase] Create", "Enqueue", err)
+				return err
+			}
+			return nil
+		}
+
+		for _, variantReq := range req.Variants {
+			variant := &domain.ProductVariant{
+				ProductID: product.ID,
+				SKU:       variantReq.SKU,
+				Options:   variantReq.Options,
+				Price:     variantReq.Price,
+				ImageURL:  variantReq.ImageURL,
+			}
+			if err := u.variantRepo.Create(ctx, variant); err != nil {
+				slog.ErrorContext(ctx, "[productWriteUsecase] Create", "variantRepository", err)
+				return err
+			}
+
+			// init stock
+			err := u.outboxRepo.Enqueue(ctx, domain.OutboxTopicInitStock, domain.InitStockRequest{
+				ShopID:    product.ShopID,
+				ProductID: product.ID,
+				VariantID: variant.ID,
+			})
+			if err != nil {
+				slog.ErrorContext(ctx, "[productWriteUsecase] Create", "Enqueue", err)
+				return err
+			}
+		}
 		return nil
 	})
 	if err != nil {
@@ -54,41 +126,239 @@ func (u *productWriteUsecase) Create(ctx context.Context, shopID int64, req *dom
 		return nil, err
 	}
 
+	invalidateProduct(ctx, u.productCache, product.ID)
 	slog.InfoContext(ctx, "[productWriteUsecase] success Create", "product_id", product.ID)
 	return &domain.CreateProductResponse{
 		ID: product.ID,
 	}, nil
 }
 
-func (u *productWriteUsecase) Update(ctx context.Context, id int64, req *domain.UpdateProductRequest) (*domain.Product, error) {
-	product, err := u.productReadRepo.GetByID(ctx, id)
+func (u *productWriteUsecase) Update(ctx context.Context, id int64, version int64, req *domain.UpdateProductRequest) (*domain.Product, error) {
+	product, err := u.getOwnedProduct(ctx, id, version)
+	if err != nil {
+		slog.ErrorContext(ctx, "[productWriteUsecase] Update", "getOwnedProduct", err)
+		return nil, err
+	}
+
+	category, err := resolveCategory(ctx, u.categoryRepo, req.CategoryID)
+	if err != nil {
+		slog.ErrorContext(ctx, "[productWriteUsecase] Update", "resolveCategory", err)
+		return nil, err
+	}
+
+	attributes, err := validateAttributes(ctx, u.attributeRepo, category.Slug, req.Attributes)
 	if err != nil {
-		slog.ErrorContext(ctx, "[productWriteUsecase] Update", "GetByID", err)
+		slog.ErrorContext(ctx, "[productWriteUsecase] Update", "validateAttributes", err)
 		return nil, err
 	}
 
+	imageChanged := req.ImageURL != product.ImageURL
+	if imageChanged {
+		if err := validateImageURL(u.cfg, req.ImageURL); err != nil {
+			slog.ErrorContext(ctx, "[productWriteUsecase] Update", "validateImageURL", err)
+			return nil, err
+		}
+	}
+
+	previousPrice, previousActive := product.Price, product.Active
 	product.Name = req.Name
 	product.Description = req.Description
 	product.Price = req.Price
-	product.Category = req.Category
+	product.CategoryID = category.ID
+	product.Category = category.Slug
 	product.ImageURL = req.ImageURL
-	product.ShopID = req.ShopID
+	product.Active = *req.Active
+	product.Attributes = attributes
 
-	if err := u.productWriteRepo.Update(ctx, product); err != nil {
-		slog.ErrorContext(ctx, "[productWriteUsecase] Update", "Update", err)
+	err = u.txManager.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := u.productWriteRepo.Update(ctx, product); err != nil {
+			slog.ErrorContext(ctx, "[productWriteUsecase] Update", "Update", err)
+			return err
+		}
+
+		if imageChanged {
+			if err := u.imageRepo.SetPrimaryByURL(ctx, product.ID, product.ImageURL); err != nil {
+				slog.ErrorContext(ctx, "[productWriteUsecase] Update", "SetPrimaryByURL", err)
+				return err
+			}
+		}
+		return enqueueProductEvents(ctx, u.outboxRepo, productUpdateEvents(product, previousPrice, previousActive)...)
+	})
+	if err != nil {
 		return nil, err
 	}
 
+	invalidateProduct(ctx, u.productCache, product.ID)
 	slog.InfoContext(ctx, "[productWriteUsecase] success Update", "product_id", product.ID)
 	return product, nil
 }
 
-func (u *productWriteUsecase) SetActiveStatus(ctx context.Context, id int64, active bool) error {
-	if err := u.productWriteRepo.SetActiveStatus(ctx, id, active); err != nil {
-		slog.ErrorContext(ctx, "[productWriteUsecase] SetActiveStatus", "SetActiveStatus", err)
-		return err
+func (u *productWriteUsecase) Patch(ctx context.Context, id int64, version int64, patch []byte) (*domain.Product, error) {
+	product, err := u.getOwnedProduct(ctx, id, version)
+	if err != nil {
+		slog.ErrorContext(ctx, "[productWriteUsecase] Patch", "getOwnedProduct", err)
+		return nil, err
+	}
+
+	current, err := json.Marshal(domain.PatchProductRequest{
+		Name:        product.Name,
+		Description: product.Description,
+		Price:       product.Price,
+		CategoryID:  product.CategoryID,
+		ImageURL:    product.ImageURL,
+		Active:      product.Active,
+		Attributes:  product.Attributes,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "[productWriteUsecase] Patch", "json.Marshal", err)
+		return nil, domain.ErrInternal
+	}
+
+	patched, err := pkg.MergePatch(current, patch)
+	if err != nil {
+		slog.ErrorContext(ctx, "[productWriteUsecase] Patch", "MergePatch", err)
+		return nil, domain.ErrBadRequest
+	}
+
+	var req domain.PatchProductRequest
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		slog.ErrorContext(ctx, "[productWriteUsecase] Patch", "decode", err)
+		return nil, fmt.Errorf("%w: %s", domain.ErrValidation, err.Error())
+	}
+
+	if err := u.validator.Struct(req); err != nil {
+		slog.ErrorContext(ctx, "[productWriteUsecase] Patch", "validation", err)
+		return nil, fmt.Errorf("%w: %s", domain.ErrValidation, err.Error())
+	}
+
+	category, err := resolveCategory(ctx, u.categoryRepo, req.CategoryID)
+	if err != nil {
+		slog.ErrorContext(ctx, "[productWriteUsecase] Patch", "resolveCategory", err)
+		return nil, err
+	}
+
+	attributes, err := validateAttributes(ctx, u.attributeRepo, category.Slug, req.Attributes)
+	if err != nil {
+		slog.ErrorContext(ctx, "[productWriteUsecase] Patch", "validateAttributes", err)
+		return nil, err
+	}
+
+	imageChanged := req.ImageURL != product.ImageURL
+	if imageChanged {
+		if err := validateImageURL(u.cfg, req.ImageURL); err != nil {
+			slog.ErrorContext(ctx, "[productWriteUsecase] Patch", "validateImageURL", err)
+			return nil, err
+		}
+	}
+
+	previousPrice, previousActive := product.Price, product.Active
+	product.Name = req.Name
+	product.Description = req.Description
+	product.Price = req.Price
+	product.CategoryID = category.ID
+	product.Category = category.Slug
+	product.ImageURL = req.ImageURL
+	product.Active = req.Active
+	product.Attributes = attributes
+
+	err = u.txManager.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := u.productWriteRepo.Update(ctx, product); err != nil {
+			slog.ErrorContext(ctx, "[productWriteUsecase] Patch", "Update", err)
+			return err
+		}
+
+		if imageChanged {
+			if err := u.imageRepo.SetPrimaryByURL(ctx, product.ID, product.ImageURL); err != nil {
+				slog.ErrorContext(ctx, "[productWriteUsecase] Patch", "SetPrimaryByURL", err)
+				return err
+			}
+		}
+		return enqueueProductEvents(ctx, u.outboxRepo, productUpdateEvents(product, previousPrice, previousActive)...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	invalidateProduct(ctx, u.productCache, product.ID)
+	slog.InfoContext(ctx, "[productWriteUsecase] success Patch", "product_id", product.ID)
+	return product, nil
+}
+
+func (u *productWriteUsecase) SetActiveStatus(ctx context.Context, id int64, version int64, active bool) (int64, error) {
+	product, err := u.getOwnedProduct(ctx, id, version)
+	if err != nil {
+		slog.ErrorContext(ctx, "[productWriteUsecase] SetActiveStatus", "getOwnedProduct", err)
+		return 0, err
 	}
-	slog.InfoContext(ctx, "[productWriteUsecase] success SetActiveStatus", "product_id", id)
 
-	return nil
+	var newVersion int64
+	err = u.txManager.WithTransaction(ctx, func(ctx context.Context) error {
+		newVersion, err = u.productWriteRepo.SetActiveStatus(ctx, id, version, active)
+		if err != nil {
+			slog.ErrorContext(ctx, "[productWriteUsecase] SetActiveStatus", "SetActiveStatus", err)
+			return err
+		}
+
+		product.Active = active
+		product.Version = newVersion
+		product.UpdatedAt = time.Now()
+		return enqueueProductEvents(ctx, u.outboxRepo, newProductEvent(activeEventType(active), product))
+	})
+	if err != nil {
+		return 0, err
+	}
+	invalidateProduct(ctx, u.productCache, id)
+	slog.InfoContext(ctx, "[productWriteUsecase] success SetActiveStatus", "product_id", id, "version", newVersion)
+
+	return newVersion, nil
+}
+
+// getOwnedProduct loads the product owned by the authenticated seller and makes
+// sure it is still at the version the client based its change on.
+func (u *productWriteUsecase) getOwnedProduct(ctx context.Context, id int64, version int64) (*domain.Product, error) {
+	product, err := loadOwnedProduct(ctx, u.productWriteRepo, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if product.Version != version {
+		slog.ErrorContext(ctx, "[productWriteUsecase] getOwnedProduct", "product_id", id, "version", product.Version, "if_match", version, "error", domain.ErrPreconditionFailed)
+		return nil, domain.ErrPreconditionFailed
+	}
+
+	return product, nil
+}
+
+// loadOwnedProduct loads the product regardless of its active status and makes
+// sure it belongs to the shop of the authenticated seller.
+func loadOwnedProduct(ctx context.Context, productWriteRepo domain.ProductWriteRepository, id int64) (*domain.Product, error) {
+	shopID, err := ctxutil.GetShopIDCtx(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "[loadOwnedProduct] GetShopIDCtx", "error", err)
+		return nil, domain.ErrUnauthorized
+	}
+
+	product, err := productWriteRepo.GetByID(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "[loadOwnedProduct] GetByID", "error", err)
+		return nil, err
+	}
+
+	if product.ShopID != shopID {
+		slog.ErrorContext(ctx, "[loadOwnedProduct] ownership", "product_id", id, "shop_id", shopID, "error", domain.ErrForbidden)
+		return nil, domain.ErrForbidden
+	}
+
+	return product, nil
+}
+
+// invalidateProduct drops the cached details of a product after a committed
+// write. A failure only leaves a stale entry until its TTL expires, so it is
+// logged rather than failing the request.
+func invalidateProduct(ctx context.Context, productCache domain.ProductCacheInvalidator, id int64) {
+	if err := productCache.Invalidate(ctx, id); err != nil {
+		slog.WarnContext(ctx, "[invalidateProduct] failed to invalidate product cache", "product_id", id, "error", err)
+	}
 }
@@ -0,0 +1,85 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"product-service/app/domain"
+	"product-service/config"
+	"product-service/pkg/ctxutil"
+	"testing"
+)
+
+func TestWritesRequireOwnership(t *testing.T) {
+	active := true
+	repo := &fakeProductWriteRepository{product: &domain.Product{ID: 1, ShopID: 2, Version: 3}}
+	cfg := &config.Config{}
+	products := &productWriteUsecase{productWriteRepo: repo, cfg: cfg}
+	images := &imageUsecase{productWriteRepo: repo, cfg: cfg}
+	variants := &variantUsecase{productWriteRepo: repo, cfg: cfg}
+
+	writes := []struct {
+		name  string
+		write func(ctx context.Context, productID int64) error
+	}{
+		{name: "Update", write: func(ctx context.Context, productID int64) error {
+			_, err := products.Update(ctx, productID, 3, &domain.UpdateProductRequest{Name: "Lamp", Active: &active})
+			return err
+		}},
+		{name: "Patch", write: func(ctx context.Context, productID int64) error {
+			_, err := products.Patch(ctx, productID, 3, []byte(`{"name":"Lamp"}`))
+			return err
+		}},
+		{name: "SetActiveStatus", write: func(ctx context.Context, productID int64) error {
+			_, err := products.SetActiveStatus(ctx, productID, 3, false)
+			return err
+		}},
+		{name: "AddImage", write: func(ctx context.Context, productID int64) error {
+			_, err := images.Add(ctx, productID, &domain.AddImageRequest{URL: "https://cdn.example.com/a.jpg"})
+			return err
+		}},
+		{name: "SetPrimaryImage", write: func(ctx context.Context, productID int64) error {
+			_, err := images.SetPrimary(ctx, productID, 5)
+			return err
+		}},
+		{name: "ReorderImages", write: func(ctx context.Context, productID int64) error {
+			_, err := images.Reorder(ctx, productID, &domain.ReorderImagesRequest{ImageIDs: []int64{5}})
+			return err
+		}},
+		{name: "DeleteImage", write: func(ctx context.Context, productID int64) error {
+			_, err := images.Delete(ctx, productID, 5)
+			return err
+		}},
+		{name: "CreateVariant", write: func(ctx context.Context, productID int64) error {
+			_, err := variants.Create(ctx, productID, &domain.CreateVariantRequest{SKU: "LAMP-RED"})
+			return err
+		}},
+		{name: "UpdateVariant", write: func(ctx context.Context, productID int64) error {
+			_, err := variants.Update(ctx, productID, 7, &domain.UpdateVariantRequest{SKU: "LAMP-RED"})
+			return err
+		}},
+		{name: "DeleteVariant", write: func(ctx context.Context, productID int64) error {
+			return variants.Delete(ctx, productID, 7)
+		}},
+	}
+
+	callers := []struct {
+		name      string
+		ctx       context.Context
+		productID int64
+		wantErr   error
+	}{
+		{name: "other shop", ctx: context.WithValue(context.Background(), ctxutil.ShopIDKey, int64(9)), productID: 1, wantErr: domain.ErrForbidden},
+		{name: "missing product", ctx: context.WithValue(context.Background(), ctxutil.ShopIDKey, int64(2)), productID: 99, wantErr: domain.ErrNotFound},
+		{name: "no shop", ctx: context.Background(), productID: 1, wantErr: domain.ErrUnauthorized},
+	}
+
+	for _, write := range writes {
+		for _, caller := range callers {
+			t.Run(write.name+"/"+caller.name, func(t *testing.T) {
+				if err := write.write(caller.ctx, caller.productID); !errors.Is(err, caller.wantErr) {
+					t.Fatalf("err = %v, want %v", err, caller.wantErr)
+				}
+			})
+		}
+	}
+}
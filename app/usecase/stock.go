@@ -2,24 +2,42 @@ package usecase
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"product-service/app/domain"
 	"product-service/config"
+	"sync/atomic"
 )
 
 type stockUsecase struct {
 	stockRepository domain.StockRepository
+	deadLetterRepo  domain.DeadLetterRepository
 	cfg             *config.Config
+	staleUpdates    atomic.Int64
 }
 
-func NewStockUsecase(stockRepository domain.StockRepository, cfg *config.Config) domain.StockUsecase {
+func NewStockUsecase(stockRepository domain.StockRepository, deadLetterRepo domain.DeadLetterRepository, cfg *config.Config) domain.StockUsecase {
 	return &stockUsecase{
 		stockRepository: stockRepository,
+		deadLetterRepo:  deadLetterRepo,
 		cfg:             cfg,
 	}
 }
 
 func (u *stockUsecase) UpdateStock(ctx context.Context, msg domain.StockMessage) error {
+	if kind, version := msg.OrderingKey(); version > 0 {
+		return u.updateStockIfNewer(ctx, msg, kind, version)
+	}
+	slog.WarnContext(ctx, "[stockUsecase] UpdateStock message without version", "product_id", msg.ProductID, "variant_id", msg.VariantID)
+
+	if msg.VariantID > 0 {
+		if err := u.stockRepository.CacheVariantStock(ctx, msg.VariantID, msg.Available); err != nil {
+			slog.ErrorContext(ctx, "[stockUsecase] UpdateStock", "cacheVariantStock", err)
+			return err
+		}
+		return nil
+	}
+
 	// Cache the stock
 	if err := u.stockRepository.CacheStock(ctx, msg.ProductID, msg.Available); err != nil {
 		slog.ErrorContext(ctx, "[stockUsecase] UpdateStock", "cacheStock", err)
@@ -28,3 +46,71 @@ func (u *stockUsecase) UpdateStock(ctx context.Context, msg domain.StockMessage)
 
 	return nil
 }
+
+// updateStockIfNewer ignores redelivered or delayed messages older than the
+// stock already cached.
+func (u *stockUsecase) updateStockIfNewer(ctx context.Context, msg domain.StockMessage, kind string, version int64) error {
+	var applied bool
+	var err error
+	if msg.VariantID > 0 {
+		applied, err = u.stockRepository.CompareAndSetVariantStock(ctx, msg.VariantID, msg.Available, kind, version)
+	} else {
+		applied, err = u.stockRepository.CompareAndSetStock(ctx, msg.ProductID, msg.Available, kind, version)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "[stockUsecase] UpdateStock", "compareAndSet", err)
+		return err
+	}
+
+	if !applied {
+		stale := u.staleUpdates.Add(1)
+		slog.WarnContext(ctx, "[stockUsecase] UpdateStock ignored stale update",
+			"product_id", msg.ProductID, "variant_id", msg.VariantID, "kind", kind, "version", version, "stale_total", stale)
+	}
+	return nil
+}
+
+func (u *stockUsecase) DeadLetter(ctx context.Context, letter *domain.DeadLetter) error {
+	if err := u.deadLetterRepo.Publish(ctx, letter); err != nil {
+		slog.ErrorContext(ctx, "[stockUsecase] DeadLetter", "publish", err)
+		return err
+	}
+
+	slog.WarnContext(ctx, "[stockUsecase] message dead-lettered",
+		"subject", letter.Subject, "original_sequence", letter.OriginalSequence, "reason", letter.Reason, "deliveries", letter.Deliveries)
+	return nil
+}
+
+func (u *stockUsecase) ListDeadLetters(ctx context.Context, afterSequence uint64, limit int) ([]*domain.DeadLetter, error) {
+	letters, err := u.deadLetterRepo.List(ctx, afterSequence, limit)
+	if err != nil {
+		slog.ErrorContext(ctx, "[stockUsecase] ListDeadLetters", "repository", err)
+		return nil, err
+	}
+	return letters, nil
+}
+
+// ReplayDeadLetter sends a dead letter back to its original subject and
+// removes it from the dead letter subject.
+func (u *stockUsecase) ReplayDeadLetter(ctx context.Context, sequence uint64) error {
+	letter, err := u.deadLetterRepo.Get(ctx, sequence)
+	if err != nil {
+		slog.ErrorContext(ctx, "[stockUsecase] ReplayDeadLetter", "get", err)
+		return err
+	}
+	if letter.Subject == "" {
+		return fmt.Errorf("%w: dead letter %d has no original subject", domain.ErrValidation, sequence)
+	}
+
+	if err := u.deadLetterRepo.Republish(ctx, letter); err != nil {
+		slog.ErrorContext(ctx, "[stockUsecase] ReplayDeadLetter", "republish", err)
+		return err
+	}
+	if err := u.deadLetterRepo.Delete(ctx, sequence); err != nil {
+		slog.ErrorContext(ctx, "[stockUsecase] ReplayDeadLetter", "delete", err)
+		return err
+	}
+
+	slog.InfoContext(ctx, "[stockUsecase] success ReplayDeadLetter", "sequence", sequence, "subject", letter.Subject)
+	return nil
+}
@@ -0,0 +1,261 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"product-service/app/domain"
+	"product-service/config"
+)
+
+type variantUsecase struct {
+	productReadRepo  domain.ProductReadRepository
+	productWriteRepo domain.ProductWriteRepository
+	variantRepo      domain.VariantRepository
+	stockRepo        domain.StockRepository
+	outboxRepo       domain.OutboxRepository
+	txManager        domain.TransactionManager
+	productCache     domain.ProductCacheInvalidator
+	cfg              *config.Config
+}
+
+func NewVariantUsecase(productReadRepo domain.ProductReadRepository, productWriteRepo domain.ProductWriteRepository, variantRepo domain.VariantRepository, stockRepo domain.StockRepository, outboxRepo domain.OutboxRepository, txManager domain.TransactionManager, productCache domain.ProductCacheInvalidator, cfg *config.Config) domain.VariantUsecase {
+	return &variantUsecase{productReadRepo, productWriteRepo, variantRepo, stockRepo, outboxRepo, txManager, productCache, cfg}
+}
+
+func (u *variantUsecase) GetByProductID(ctx context.Context, productID int64) ([]*domain.VariantResponse, error) {
+	product, err := u.productReadRepo.GetByID(ctx, productID)
+	if err != nil {
+		slog.ErrorContext(ctx, "[variantUsecase] GetByProductID", "GetByID", err)
+		return nil, err
+	}
+
+	variants, err := u.variantRepo.GetByProductID(ctx, product.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "[variantUsecase] GetByProductID", "variantRepository", err)
+		return nil, err
+	}
+
+	res, err := variantsWithStock(ctx, u.stockRepo, product, variants)
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		res = []*domain.VariantResponse{}
+	}
+
+	return res, nil
+}
+
+func (u *variantUsecase) Create(ctx context.Context, productID int64, req *domain.CreateVariantRequest) (*domain.ProductVariant, error) {
+	product, err := loadOwnedProduct(ctx, u.productWriteRepo, productID)
+	if err != nil {
+		slog.ErrorContext(ctx, "[variantUsecase] Create", "loadOwnedProduct", err)
+		return nil, err
+	}
+
+	if req.ImageURL != "" {
+		if err := validateImageURL(u.cfg, req.ImageURL); err != nil {
+			slog.ErrorContext(ctx, "[variantUsecase] Create", "validateImageURL", err)
+			return nil, err
+		}
+	}
+
+	variant := &domain.ProductVariant{
+		ProductID: product.ID,
+		SKU:       req.SKU,
+		Options:   req.Options,
+		Price:     req.Price,
+		ImageURL:  req.ImageURL,
+	}
+
+	err = u.changeVariants(ctx, product.ID, func(ctx context.Context) error {
+		if err := u.variantRepo.Create(ctx, variant); err != nil {
+			slog.ErrorContext(ctx, "[variantUsecase] Create", "repository", err)
+			return err
+		}
+
+		// init stock
+		err := u.outboxRepo.Enqueue(ctx, domain.OutboxTopicInitStock, domain.InitStockRequest{
+			ShopID:    product.ShopID,
+			ProductID: product.ID,
+			VariantID: variant.ID,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "[variantUsecase] Create", "Enqueue", err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "[variantUsecase] Create", "transaction", err)
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "[variantUsecase] success Create", "product_id", product.ID, "variant_id", variant.ID)
+	return variant, nil
+}
+
+func (u *variantUsecase) Update(ctx context.Context, productID, id int64, req *domain.UpdateVariantRequest) (*domain.ProductVariant, error) {
+	if _, err := loadOwnedProduct(ctx, u.productWriteRepo, productID); err != nil {
+		slog.ErrorContext(ctx, "[variantUsecase] Update", "loadOwnedProduct", err)
+		return nil, err
+	}
+
+	if req.ImageURL != "" {
+		if err := validateImageURL(u.cfg, req.ImageURL); err != nil {
+			slog.ErrorContext(ctx, "[variantUsecase] Update", "validateImageURL", err)
+			return nil, err
+		}
+	}
+
+	variant, err := u.variantRepo.GetByID(ctx, productID, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "[variantUsecase] Update", "GetByID", err)
+		return nil, err
+	}
+
+	variant.SKU = req.SKU
+	variant.Options = req.Options
+	variant.Price = req.Price
+	variant.ImageURL = req.ImageURL
+
+	err = u.changeVariants(ctx, productID, func(ctx context.Context) error {
+		return u.variantRepo.Update(ctx, variant)
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "[variantUsecase] Update", "repository", err)
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "[variantUsecase] success Update", "product_id", productID, "variant_id", id)
+	return variant, nil
+}
+
+// Delete removes a variant. Deleting the last one moves the product stock
+// back to product level, so its warehouse stock is initialised again; the
+// warehouse ignores the call if the product-level stock still exists.
+func (u *variantUsecase) Delete(ctx context.Context, productID, id int64) error {
+	product, err := loadOwnedProduct(ctx, u.productWriteRepo, productID)
+	if err != nil {
+		slog.ErrorContext(ctx, "[variantUsecase] Delete", "loadOwnedProduct", err)
+		return err
+	}
+
+	err = u.changeVariants(ctx, productID, func(ctx context.Context) error {
+		if err := u.variantRepo.Delete(ctx, productID, id); err != nil {
+			slog.ErrorContext(ctx, "[variantUsecase] Delete", "repository", err)
+			return err
+		}
+
+		remaining, err := u.variantRepo.GetByProductID(ctx, productID)
+		if err != nil {
+			slog.ErrorContext(ctx, "[variantUsecase] Delete", "GetByProductID", err)
+			return err
+		}
+		if len(remaining) > 0 {
+			return nil
+		}
+		err = u.outboxRepo.Enqueue(ctx, domain.OutboxTopicInitStock, domain.InitStockRequest{
+			ShopID:    product.ShopID,
+			ProductID: productID,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "[variantUsecase] Delete", "Enqueue", err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "[variantUsecase] success Delete", "product_id", productID, "variant_id", id)
+	return nil
+}
+
+// changeVariants applies a variant change in a transaction. The product
+// version is bumped first, which locks the product row so variant changes of
+// a product are serialised, and outstanding ETags of the product go stale.
+// product.updated is recorded in the same transaction.
+func (u *variantUsecase) changeVariants(ctx context.Context, productID int64, change func(ctx context.Context) error) error {
+	err := u.txManager.WithTransaction(ctx, func(ctx context.Context) error {
+		if _, err := u.productWriteRepo.BumpVersion(ctx, productID); err != nil {
+			slog.ErrorContext(ctx, "[variantUsecase] changeVariants", "BumpVersion", err)
+			return err
+		}
+
+		if err := change(ctx); err != nil {
+			return err
+		}
+
+		product, err := u.productWriteRepo.GetByID(ctx, productID)
+		if err != nil {
+			slog.ErrorContext(ctx, "[variantUsecase] changeVariants", "GetByID", err)
+			return err
+		}
+		return enqueueProductEvents(ctx, u.outboxRepo, newProductEvent(domain.ProductEventUpdated, product))
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateProduct(ctx, u.productCache, productID)
+	return nil
+}
+
+// variantsWithStock maps variants to responses, resolving the effective price
+// and the per-variant stock from cache or the warehouse service. A variant
+// whose stock is unavailable is reported with an unknown stock status.
+func variantsWithStock(ctx context.Context, stockRepo domain.StockRepository, product *domain.Product, variants []*domain.ProductVariant) ([]*domain.VariantResponse, error) {
+	var res []*domain.VariantResponse
+	for _, variant := range variants {
+		status := ""
+		stock, err := stockRepo.GetVariantStock(ctx, variant.ID)
+		if err != nil {
+			slog.WarnContext(ctx, "[variantsWithStock] GetVariantStock", "error", err)
+
+			stock, err = stockRepo.FetchVariantStockFromService(ctx, variant.ID)
+			switch {
+			case errors.Is(err, domain.ErrStockUnavailable):
+				slog.WarnContext(ctx, "[variantsWithStock] FetchVariantStockFromService", "error", err)
+				status = domain.StockStatusUnknown
+			case err != nil:
+				slog.ErrorContext(ctx, "[variantsWithStock] FetchVariantStockFromService", "error", err)
+				return nil, err
+			default:
+				if err := stockRepo.FillVariantStock(ctx, variant.ID, stock); err != nil {
+					slog.ErrorContext(ctx, "[variantsWithStock] FillVariantStock", "error", err)
+					return nil, err
+				}
+			}
+		}
+		if status == "" {
+			status = stockStatus(stock)
+		}
+
+		price := product.Price
+		if variant.Price != nil {
+			price = *variant.Price
+		}
+		imageURL := variant.ImageURL
+		if imageURL == "" {
+			imageURL = product.ImageURL
+		}
+
+		res = append(res, &domain.VariantResponse{
+			ID:          variant.ID,
+			ProductID:   variant.ProductID,
+			SKU:         variant.SKU,
+			Options:     variant.Options,
+			Price:       price,
+			ImageURL:    imageURL,
+			Stock:       stock,
+			StockStatus: status,
+			CreatedAt:   variant.CreatedAt,
+			UpdatedAt:   variant.UpdatedAt,
+		})
+	}
+
+	return res, nil
+}
@@ -0,0 +1,96 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"product-service/app/domain"
+	"product-service/config"
+	"product-service/pkg/ctxutil"
+	"slices"
+	"testing"
+)
+
+// memoryVariantRepository keeps the variants of products in memory.
+type memoryVariantRepository struct {
+	domain.VariantRepository
+	variants map[int64][]*domain.ProductVariant
+	nextID   int64
+}
+
+func (r *memoryVariantRepository) GetByProductID(ctx context.Context, productID int64) ([]*domain.ProductVariant, error) {
+	return r.variants[productID], nil
+}
+
+func (r *memoryVariantRepository) Create(ctx context.Context, variant *domain.ProductVariant) error {
+	r.nextID++
+	variant.ID = r.nextID
+	r.variants[variant.ProductID] = append(r.variants[variant.ProductID], variant)
+	return nil
+}
+
+func (r *memoryVariantRepository) Delete(ctx context.Context, productID, id int64) error {
+	r.variants[productID] = slices.DeleteFunc(r.variants[productID], func(variant *domain.ProductVariant) bool {
+		return variant.ID == id
+	})
+	return nil
+}
+
+type recordingProductCache struct {
+	invalidated []int64
+}
+
+func (c *recordingProductCache) Invalidate(ctx context.Context, id int64) error {
+	c.invalidated = append(c.invalidated, id)
+	return nil
+}
+
+func TestVariantWritesUpdateProduct(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ctxutil.ShopIDKey, int64(2))
+	productRepo := &fakeProductWriteRepository{product: &domain.Product{ID: 1, ShopID: 2, Version: 3}}
+	variantRepo := &memoryVariantRepository{variants: map[int64][]*domain.ProductVariant{}, nextID: 10}
+	outboxRepo := &recordingOutboxRepository{}
+	productCache := &recordingProductCache{}
+	u := &variantUsecase{
+		productWriteRepo: productRepo,
+		variantRepo:      variantRepo,
+		outboxRepo:       outboxRepo,
+		txManager:        passthroughTxManager{},
+		productCache:     productCache,
+		cfg:              &config.Config{},
+	}
+
+	variant, err := u.Create(ctx, 1, &domain.CreateVariantRequest{SKU: "LAMP-RED", Options: map[string]string{"color": "red"}})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := u.Delete(ctx, 1, variant.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	want := []any{
+		domain.InitStockRequest{ShopID: 2, ProductID: 1, VariantID: variant.ID},
+		domain.ProductEventUpdated + "@4",
+		// the last variant is gone, so the stock is back at product level
+		domain.InitStockRequest{ShopID: 2, ProductID: 1},
+		domain.ProductEventUpdated + "@5",
+	}
+	var got []any
+	for _, message := range outboxRepo.messages {
+		if event, ok := message.(domain.ProductEventMessage); ok {
+			got = append(got, fmt.Sprintf("%s@%d", event.Event.Type, event.Event.Version))
+			continue
+		}
+		got = append(got, message)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("outbox = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("outbox[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+	if !slices.Equal(productCache.invalidated, []int64{1, 1}) {
+		t.Fatalf("invalidated = %v, want the product after each write", productCache.invalidated)
+	}
+}
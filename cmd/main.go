@@ -7,13 +7,17 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
+	"product-service/app/domain"
 	"product-service/app/handler"
 	"product-service/app/middleware"
+	cacherepo "product-service/app/repository/cache_repo"
 	"product-service/app/repository/db"
+	eventrepo "product-service/app/repository/event_repo"
 	stockrepo "product-service/app/repository/stock_repo"
 	"product-service/app/usecase"
 	"product-service/config"
 	"product-service/pkg/logger"
+	"product-service/pkg/metrics"
 	"strings"
 	"syscall"
 	"time"
@@ -23,6 +27,7 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/healthcheck"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
 	"github.com/redis/go-redis/v9"
@@ -32,6 +37,14 @@ func main() {
 	// init logger
 	logger.InitLogger()
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(context.Background(), os.Args[2:]); err != nil {
+			slog.Error("migrate failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// init config
 	cfg, err := config.InitConfig(context.Background())
 	if err != nil {
@@ -39,12 +52,27 @@ func main() {
 		return
 	}
 
+	if cfg.Db.AutoMigrate {
+		if err := autoMigrate(cfg.Db); err != nil {
+			slog.Error("failed to apply migrations", "error", err)
+			return
+		}
+	}
+
 	// init database
-	dbConn, err := db.NewPostgres(cfg.Db)
+	dbConn, err := db.NewPool(context.Background(), cfg.Db)
 	if err != nil {
 		log.Fatalf("DB connection failed: %v", err)
 	}
-	defer dbConn.Close()
+
+	// the read replica is optional, product reads use the primary without it
+	var replicaConn *pgxpool.Pool
+	if cfg.Db.ReplicaDSN != "" {
+		replicaConn, err = db.NewReplicaPool(context.Background(), cfg.Db)
+		if err != nil {
+			log.Fatalf("DB replica connection failed: %v", err)
+		}
+	}
 
 	// init redis
 	redisClient := redis.NewClient(&redis.Options{
@@ -52,19 +80,14 @@ func main() {
 		Password: cfg.Redis.Password,
 		DB:       cfg.Redis.Db,
 	})
-	defer func() {
-		if err := redisClient.Close(); err != nil {
-			slog.Error("failed to close redis client", "error", err)
-		}
-	}()
 
 	// Connect to NATS server
-	nc, err := nats.Connect(cfg.Nats.Url) // default is nats://localhost:4222
+	natsClosed := make(chan struct{})
+	nc, err := nats.Connect(cfg.Nats.Url, nats.ClosedHandler(func(*nats.Conn) { close(natsClosed) })) // default is nats://localhost:4222
 	if err != nil {
 		slog.Error("Error connecting to NATS", "error", err)
 		return
 	}
-	defer nc.Drain()
 
 	js, err := jetstream.New(nc)
 	if err != nil {
@@ -82,22 +105,91 @@ func main() {
 		return
 	}
 
+	productSubject := cfg.Nats.ProductSubject
+	if productSubject == "" {
+		productSubject = "product"
+	}
+	_, err = js.CreateOrUpdateStream(context.Background(), jetstream.StreamConfig{
+		Name:     strings.ToUpper(productSubject),
+		Subjects: []string{productSubject + ".*"},
+		Storage:  jetstream.FileStorage,
+		// Nats-Msg-Id must still dedupe the last outbox retry of a publish
+		// whose ack was lost.
+		Duplicates: usecase.OutboxRetrySpan(cfg) + time.Minute,
+	})
+	if err != nil {
+		slog.Error("Error creating product event stream", "error", err)
+		return
+	}
+
 	reqValidator := validator.New()
-	productReadRepo := db.NewProductReadRepository(dbConn)
+	productReadRepo := cacherepo.NewProductReadRepository(db.NewProductReadRepository(dbConn, replicaConn, cfg.Db), redisClient,
+		time.Duration(cfg.Cache.ProductTTL)*time.Second, time.Duration(cfg.Db.ReadYourWritesMs)*time.Millisecond)
+	txManager := db.NewTransactionManager(dbConn)
 	productWriteRepo := db.NewProductWriteRepository(dbConn)
-	stockRepo := stockrepo.NewStockRepository(redisClient, time.Duration(0), cfg.WarehouseService.Host, cfg.InternalAuthHeader)
+	variantRepo := db.NewVariantRepository(dbConn)
+	attributeRepo := db.NewAttributeRepository(dbConn, txManager)
+	imageRepo := db.NewImageRepository(dbConn, txManager)
+	categoryRepo := db.NewCategoryRepository(dbConn)
+	outboxRepo := db.NewOutboxRepository(dbConn)
+	stockRepo := stockrepo.NewStockRepository(redisClient, time.Duration(cfg.Cache.StockTTL)*time.Second, cfg.WarehouseService, cfg.InternalAuthHeader)
+	deadLetterRepo := eventrepo.NewDeadLetterRepository(js, stream, strings.ToLower(cfg.Nats.StreamName)+".dlq")
 
-	productReadUsecase := usecase.NewProductReadUsecase(productReadRepo, stockRepo, cfg)
-	productWriteUsecase := usecase.NewProductWriteUsecase(productReadRepo, productWriteRepo, stockRepo, cfg)
-	stockUsecase := usecase.NewStockUsecase(stockRepo, cfg)
+	productReadUsecase := usecase.NewProductReadUsecase(productReadRepo, variantRepo, imageRepo, stockRepo, cfg)
+	productWriteUsecase := usecase.NewProductWriteUsecase(productReadRepo, productWriteRepo, variantRepo, attributeRepo, categoryRepo, imageRepo, outboxRepo, txManager, productReadRepo, reqValidator, cfg)
+	variantUsecase := usecase.NewVariantUsecase(productReadRepo, productWriteRepo, variantRepo, stockRepo, outboxRepo, txManager, productReadRepo, cfg)
+	attributeUsecase := usecase.NewAttributeUsecase(attributeRepo, categoryRepo, cfg)
+	imageUsecase := usecase.NewImageUsecase(productWriteRepo, imageRepo, productReadRepo, outboxRepo, txManager, cfg)
+	categoryUsecase := usecase.NewCategoryUsecase(categoryRepo, cfg)
+	stockUsecase := usecase.NewStockUsecase(stockRepo, deadLetterRepo, cfg)
+	productEventPublisher := eventrepo.NewProductEventPublisher(js, productSubject)
+	outboxRelay := usecase.NewOutboxRelay(outboxRepo, stockRepo, productEventPublisher, cfg)
 
 	productReadHandler := handler.NewProductReadHandler(productReadUsecase, reqValidator)
 	productWriteHandler := handler.NewProductWriteHandler(productWriteUsecase, reqValidator)
+	variantHandler := handler.NewVariantHandler(variantUsecase, reqValidator)
+	attributeHandler := handler.NewAttributeHandler(attributeUsecase, reqValidator)
+	imageHandler := handler.NewImageHandler(imageUsecase, reqValidator)
+	categoryHandler := handler.NewCategoryHandler(categoryUsecase, reqValidator)
+	deadLetterHandler := handler.NewDeadLetterHandler(stockUsecase)
+	metrics.RegisterDBPool("primary", dbConn)
+	if replicaConn != nil {
+		metrics.RegisterDBPool("replica", replicaConn)
+	}
+	poolStatsHandler := handler.NewPoolStatsHandler(func() map[string]domain.DBPoolStats {
+		stats := map[string]domain.DBPoolStats{"primary": db.PoolStats(dbConn)}
+		if replicaConn != nil {
+			stats["replica"] = db.PoolStats(replicaConn)
+		}
+		return stats
+	})
+
+	stockConsumerHandler := handler.NewStockConsumerHandler(stockUsecase, cfg.Nats.StockMaxDeliver)
 
-	stockConsumerHandler := handler.NewStockConsumerHandler(stockUsecase)
+	// Deliver outbox messages in the background
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		outboxRelay.Run(relayCtx)
+	}()
 
 	// Setup NATS consumer
-	handler.SetupConsumer(context.Background(), stream, stockConsumerHandler)
+	stockConsumer, err := handler.SetupConsumer(context.Background(), stream, cfg.Nats, stockConsumerHandler)
+	if err != nil {
+		slog.Error("Error setting up NATS consumer", "error", err)
+		return
+	}
+
+	readinessHandler := handler.NewReadinessHandler(
+		time.Duration(cfg.Lifecycle.ReadinessTimeoutMs)*time.Millisecond,
+		time.Duration(cfg.Lifecycle.ReadinessCacheMs)*time.Millisecond,
+		handler.ReadinessCheck{Name: "postgres", Check: dbConn.Ping},
+		handler.ReadinessCheck{Name: "redis", Check: func(ctx context.Context) error {
+			return redisClient.Ping(ctx).Err()
+		}},
+		handler.ReadinessCheck{Name: "nats", Check: nc.FlushWithContext},
+	)
 
 	// Initialize HTTP web framework
 	app := fiber.New()
@@ -105,19 +197,18 @@ func main() {
 		LivenessProbe: func(c *fiber.Ctx) bool {
 			return true
 		},
-		LivenessEndpoint: "/live",
-		ReadinessProbe: func(c *fiber.Ctx) bool {
-			return true
-		},
+		LivenessEndpoint:  "/live",
+		ReadinessProbe:    readinessHandler.Probe,
 		ReadinessEndpoint: "/ready",
 	}))
+	app.Use(middleware.MetricsMiddleware())
 	app.Use(recover.New())
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "*",
 	}))
 	app.Use(middleware.RequestIDMiddleware())
 
-	handler.SetupRouter(app, productReadHandler, productWriteHandler, cfg)
+	handler.SetupRouter(app, productReadHandler, productWriteHandler, variantHandler, attributeHandler, imageHandler, categoryHandler, deadLetterHandler, poolStatsHandler, cfg)
 
 	go func() {
 		if err := app.Listen(":" + cfg.Port); err != nil {
@@ -130,8 +221,63 @@ func main() {
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
 	slog.Info("Gracefully shutdown")
-	err = app.Shutdown()
-	if err != nil {
+
+	// Stop taking work in order: traffic first, then messages, then the
+	// relay, and only then the connections they all depend on. Requests keep
+	// being served for the drain delay, until the failing readiness probe has
+	// taken the instance out of the load balancer. The drain delay is part of
+	// the shutdown timeout, so the whole shutdown never takes longer.
+	readinessHandler.MarkNotReady()
+
+	shutdownTimeout := time.Duration(cfg.Lifecycle.ShutdownTimeoutSeconds) * time.Second
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = 30 * time.Second
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	drainDelay := time.Duration(cfg.Lifecycle.ShutdownDrainDelayMs) * time.Millisecond
+	if drainDelay <= 0 {
+		drainDelay = 15 * time.Second
+	}
+	slog.Info("Waiting for traffic to drain", "delay", drainDelay, "shutdown_timeout", shutdownTimeout)
+	select {
+	case <-time.After(drainDelay):
+	case <-shutdownCtx.Done():
+		slog.Warn("Drain delay used up the shutdown timeout")
+	}
+
+	if err := stockConsumer.Stop(shutdownCtx); err != nil {
+		slog.Warn("NATS consumer did not stop cleanly", "err", err)
+	}
+
+	if err := app.ShutdownWithContext(shutdownCtx); err != nil {
 		slog.Warn("Unfortunately the shutdown wasn't smooth", "err", err)
 	}
+
+	stopRelay()
+	select {
+	case <-relayDone:
+	case <-shutdownCtx.Done():
+		slog.Warn("Outbox relay did not stop before the shutdown deadline")
+	}
+
+	if err := nc.Drain(); err != nil {
+		slog.Warn("Failed to drain NATS connection", "err", err)
+	}
+	select {
+	case <-natsClosed:
+	case <-shutdownCtx.Done():
+		slog.Warn("NATS connection did not drain before the shutdown deadline")
+		nc.Close()
+	}
+
+	if err := redisClient.Close(); err != nil {
+		slog.Error("failed to close redis client", "error", err)
+	}
+	if replicaConn != nil {
+		replicaConn.Close()
+	}
+	dbConn.Close()
+	slog.Info("Shutdown complete")
 }
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"product-service/app/repository/db"
+	"product-service/config"
+	"regexp"
+	"strconv"
+)
+
+const migrateUsage = `usage: product-service migrate <command>
+
+commands:
+  up             apply all pending migrations
+  down [steps]   roll back the last steps migrations (default 1)
+  status         list migrations and whether they are applied
+  create <name>  add an empty up/down migration pair to ./migrations`
+
+var (
+	migrationFileRe = regexp.MustCompile(`^(\d+)_.+\.(up|down)\.sql$`)
+	migrationNameRe = regexp.MustCompile(`^[a-z0-9_]+$`)
+)
+
+// runMigrate implements the migrate subcommand.
+func runMigrate(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	// create only touches the source tree and needs no configuration.
+	if args[0] == "create" {
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		return createMigration("migrations", args[1])
+	}
+
+	cfg, err := config.InitConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	migrator, err := db.NewMigrator(cfg.Db)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	switch args[0] {
+	case "up":
+		if err := migrator.Up(); err != nil {
+			return err
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil {
+				return fmt.Errorf("invalid steps %q: %w", args[1], err)
+			}
+		}
+		if err := migrator.Down(steps); err != nil {
+			return err
+		}
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied"
+			}
+			fmt.Printf("%06d  %s\n", status.Version, state)
+		}
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	version, dirty, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("database version: %d (dirty: %t)\n", version, dirty)
+	return nil
+}
+
+// createMigration writes an empty migration pair numbered after the highest
+// version found in dir.
+func createMigration(dir, name string) error {
+	if !migrationNameRe.MatchString(name) {
+		return fmt.Errorf("migration name %q must be snake_case", name)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var next uint64 = 1
+	for _, entry := range entries {
+		match := migrationFileRe.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return err
+		}
+		next = max(next, version+1)
+	}
+
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%06d_%s.%s.sql", next, name, direction))
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+		fmt.Println("created", path)
+	}
+	return nil
+}
+
+func autoMigrate(cfg config.DbConfig) error {
+	migrator, err := db.NewMigrator(cfg)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	return migrator.Up()
+}
@@ -18,6 +18,10 @@ type Config struct {
 	WarehouseService   WarehouseServiceConfig `mapstructure:",squash"`
 	Nats               NatsConfig             `mapstructure:",squash"`
 	Jwt                JwtConfig              `mapstructure:",squash"`
+	Image              ImageConfig            `mapstructure:",squash"`
+	Cache              CacheConfig            `mapstructure:",squash"`
+	Outbox             OutboxConfig           `mapstructure:",squash"`
+	Lifecycle          LifecycleConfig        `mapstructure:",squash"`
 }
 
 type DbConfig struct {
@@ -27,6 +31,27 @@ type DbConfig struct {
 	Password string `mapstructure:"DB_PASSWORD" validate:"required"`
 	DbName   string `mapstructure:"DB_DBNAME" validate:"required"`
 	SSLMode  string `mapstructure:"DB_SSLMODE"`
+	// AutoMigrate applies pending migrations on boot. Replicas wait for each
+	// other on the migration lock for up to MigrationLockTimeoutSeconds.
+	AutoMigrate                 bool  `mapstructure:"DB_AUTO_MIGRATE"`
+	MigrationLockTimeoutSeconds int64 `mapstructure:"DB_MIGRATION_LOCK_TIMEOUT_SECONDS"`
+	// ReplicaDSN optionally points product reads at a read replica. Reads
+	// fall back to the primary while the replica lags more than
+	// ReplicaMaxLagMs, and for ReadYourWritesMs after a product is written.
+	// Within that window every instance also skips the product cache for it.
+	ReplicaDSN       string `mapstructure:"DB_REPLICA_DSN"`
+	ReplicaMaxLagMs  int64  `mapstructure:"DB_REPLICA_MAX_LAG_MS"`
+	ReadYourWritesMs int64  `mapstructure:"DB_READ_YOUR_WRITES_MS"`
+	// Pool settings apply to the primary and the replica pool. Zero values
+	// fall back to the pool defaults. StatementCacheMode is one of
+	// cache_statement (default), cache_describe, describe_exec, exec or
+	// simple_protocol; the last two work behind transaction poolers.
+	MaxConns                 int32  `mapstructure:"DB_MAX_CONNS"`
+	MinConns                 int32  `mapstructure:"DB_MIN_CONNS"`
+	MaxConnLifetimeSeconds   int64  `mapstructure:"DB_MAX_CONN_LIFETIME_SECONDS"`
+	MaxConnIdleSeconds       int64  `mapstructure:"DB_MAX_CONN_IDLE_SECONDS"`
+	HealthCheckPeriodSeconds int64  `mapstructure:"DB_HEALTH_CHECK_PERIOD_SECONDS"`
+	StatementCacheMode       string `mapstructure:"DB_STATEMENT_CACHE_MODE"`
 }
 
 type RedisConfig struct {
@@ -39,6 +64,19 @@ type RedisConfig struct {
 type NatsConfig struct {
 	Url        string `mapstructure:"NATS_URL" validate:"required"`
 	StreamName string `mapstructure:"NATS_STREAM_NAME" validate:"required"`
+	// ProductSubject prefixes the subjects product events are published on,
+	// e.g. product.created. It also names their stream.
+	ProductSubject string `mapstructure:"NATS_PRODUCT_SUBJECT"`
+	// StockMaxDeliver is how many times a stock message is delivered before
+	// it is moved to the dead letter subject.
+	StockMaxDeliver int `mapstructure:"NATS_STOCK_MAX_DELIVER"`
+	// The consumer settings fall back to the consumer defaults when zero.
+	// ConsumerFilterSubjects is a comma separated list of subjects.
+	ConsumerDurable        string `mapstructure:"NATS_CONSUMER_DURABLE"`
+	ConsumerFilterSubjects string `mapstructure:"NATS_CONSUMER_FILTER_SUBJECTS"`
+	ConsumerAckWaitSeconds int64  `mapstructure:"NATS_CONSUMER_ACK_WAIT_SECONDS"`
+	ConsumerMaxAckPending  int    `mapstructure:"NATS_CONSUMER_MAX_ACK_PENDING"`
+	ConsumerWorkers        int    `mapstructure:"NATS_CONSUMER_WORKERS"`
 }
 
 type JwtConfig struct {
@@ -46,8 +84,54 @@ type JwtConfig struct {
 	Expire    int64  `mapstructure:"JWT_EXPIRE" validate:"required"`
 }
 
+type ImageConfig struct {
+	// AllowedHosts is a comma separated list of hosts product images may be
+	// served from. An entry starting with a dot also matches its subdomains.
+	AllowedHosts string `mapstructure:"IMAGE_ALLOWED_HOSTS"`
+}
+
+type CacheConfig struct {
+	// ProductTTL is how long product details stay cached, in seconds. Zero
+	// disables the cache.
+	ProductTTL int64 `mapstructure:"CACHE_PRODUCT_TTL"`
+	// StockTTL is how long cached stock is kept, in seconds, so a lost stock
+	// message is healed by the next warehouse fetch. Zero falls back to the
+	// default of the stock repository.
+	StockTTL int64 `mapstructure:"CACHE_STOCK_TTL"`
+	// StockLockTTLMs is how long a replica holds the lock guarding a warehouse
+	// fetch of a stock cache miss, and how long the others wait for it. Zero
+	// falls back to 3 seconds.
+	StockLockTTLMs int64 `mapstructure:"CACHE_STOCK_LOCK_TTL_MS"`
+}
+
+// OutboxConfig tunes the outbox relay. Zero values fall back to its defaults.
+type OutboxConfig struct {
+	PollIntervalMs int64 `mapstructure:"OUTBOX_POLL_INTERVAL_MS"`
+	BatchSize      int   `mapstructure:"OUTBOX_BATCH_SIZE"`
+	MaxAttempts    int   `mapstructure:"OUTBOX_MAX_ATTEMPTS"`
+	// RetentionDays is how long delivered and dead messages are kept.
+	RetentionDays int `mapstructure:"OUTBOX_RETENTION_DAYS"`
+}
+
+// LifecycleConfig tunes readiness probes and graceful shutdown. Zero values
+// fall back to their defaults.
+type LifecycleConfig struct {
+	ReadinessTimeoutMs     int64 `mapstructure:"READINESS_TIMEOUT_MS"`
+	ReadinessCacheMs       int64 `mapstructure:"READINESS_CACHE_MS"`
+	// ShutdownTimeoutSeconds bounds the whole shutdown, ShutdownDrainDelayMs
+	// included, so the drain delay must be well below it.
+	ShutdownTimeoutSeconds int64 `mapstructure:"SHUTDOWN_TIMEOUT_SECONDS"`
+	ShutdownDrainDelayMs   int64 `mapstructure:"SHUTDOWN_DRAIN_DELAY_MS"`
+}
+
 type WarehouseServiceConfig struct {
 	Host string `mapstructure:"WAREHOUSE_SERVICE_HOST" validate:"required"`
+	// Zero values fall back to the defaults of the warehouse client.
+	TimeoutMs          int64  `mapstructure:"WAREHOUSE_SERVICE_TIMEOUT_MS"`
+	MaxRetries         int    `mapstructure:"WAREHOUSE_SERVICE_MAX_RETRIES"`
+	RetryBackoffMs     int64  `mapstructure:"WAREHOUSE_SERVICE_RETRY_BACKOFF_MS"`
+	BreakerFailures    uint32 `mapstructure:"WAREHOUSE_SERVICE_BREAKER_FAILURES"`
+	BreakerOpenSeconds int64  `mapstructure:"WAREHOUSE_SERVICE_BREAKER_OPEN_SECONDS"`
 }
 
 func InitConfig(ctx context.Context) (*Config, error) {
@@ -90,12 +174,28 @@ func InitConfig(ctx context.Context) (*Config, error) {
 		"PORT",
 		"INTERNAL_AUTH_HEADER",
 		"WAREHOUSE_SERVICE_HOST",
+		"WAREHOUSE_SERVICE_TIMEOUT_MS",
+		"WAREHOUSE_SERVICE_MAX_RETRIES",
+		"WAREHOUSE_SERVICE_RETRY_BACKOFF_MS",
+		"WAREHOUSE_SERVICE_BREAKER_FAILURES",
+		"WAREHOUSE_SERVICE_BREAKER_OPEN_SECONDS",
 		"DB_HOST",
 		"DB_PORT",
 		"DB_USERNAME",
 		"DB_PASSWORD",
 		"DB_DBNAME",
 		"DB_SSLMODE",
+		"DB_AUTO_MIGRATE",
+		"DB_MIGRATION_LOCK_TIMEOUT_SECONDS",
+		"DB_REPLICA_DSN",
+		"DB_REPLICA_MAX_LAG_MS",
+		"DB_READ_YOUR_WRITES_MS",
+		"DB_MAX_CONNS",
+		"DB_MIN_CONNS",
+		"DB_MAX_CONN_LIFETIME_SECONDS",
+		"DB_MAX_CONN_IDLE_SECONDS",
+		"DB_HEALTH_CHECK_PERIOD_SECONDS",
+		"DB_STATEMENT_CACHE_MODE",
 		"REDIS_HOST",
 		"REDIS_PORT",
 		"REDIS_PASSWORD",
@@ -104,6 +204,25 @@ func InitConfig(ctx context.Context) (*Config, error) {
 		"JWT_EXPIRE",
 		"NATS_URL",
 		"NATS_STREAM_NAME",
+		"NATS_PRODUCT_SUBJECT",
+		"NATS_STOCK_MAX_DELIVER",
+		"NATS_CONSUMER_DURABLE",
+		"NATS_CONSUMER_FILTER_SUBJECTS",
+		"NATS_CONSUMER_ACK_WAIT_SECONDS",
+		"NATS_CONSUMER_MAX_ACK_PENDING",
+		"NATS_CONSUMER_WORKERS",
+		"IMAGE_ALLOWED_HOSTS",
+		"CACHE_PRODUCT_TTL",
+		"CACHE_STOCK_TTL",
+		"CACHE_STOCK_LOCK_TTL_MS",
+		"OUTBOX_POLL_INTERVAL_MS",
+		"OUTBOX_BATCH_SIZE",
+		"OUTBOX_MAX_ATTEMPTS",
+		"OUTBOX_RETENTION_DAYS",
+		"READINESS_TIMEOUT_MS",
+		"READINESS_CACHE_MS",
+		"SHUTDOWN_TIMEOUT_SECONDS",
+		"SHUTDOWN_DRAIN_DELAY_MS",
 	}
 
 	slog.InfoContext(ctx, "[InitConfig] Environment variables debug:")
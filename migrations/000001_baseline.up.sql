@@ -0,0 +1,17 @@
+-- The products table as the service ran on it before migrations were
+-- versioned. IF NOT EXISTS lets databases created by hand adopt the
+-- migrations; everything added since then lives in later migrations.
+CREATE TABLE IF NOT EXISTS products (
+    id          BIGSERIAL PRIMARY KEY,
+    name        TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    price       BIGINT NOT NULL,
+    category    TEXT NOT NULL DEFAULT '',
+    image_url   TEXT NOT NULL DEFAULT '',
+    shop_id     BIGINT NOT NULL,
+    active      BOOLEAN NOT NULL DEFAULT true,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_products_shop_id ON products (shop_id);
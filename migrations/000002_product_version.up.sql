@@ -0,0 +1,3 @@
+-- Optimistic concurrency for product writes, see the version compare in
+-- app/repository/db/product_write.go.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS product_variants (
+    id         BIGSERIAL PRIMARY KEY,
+    product_id BIGINT NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    sku        VARCHAR(64) NOT NULL UNIQUE,
+    options    JSONB NOT NULL DEFAULT '{}',
+    price      BIGINT,
+    image_url  TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_variants_product_id ON product_variants (product_id);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS category_attributes;
+
+DROP INDEX IF EXISTS idx_products_attributes;
+
+ALTER TABLE products DROP COLUMN IF EXISTS attributes;
@@ -0,0 +1,17 @@
+ALTER TABLE products ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_products_attributes ON products USING GIN (attributes);
+
+-- options is nullable because attributes without options are stored as
+-- JSON null.
+CREATE TABLE IF NOT EXISTS category_attributes (
+    id       BIGSERIAL PRIMARY KEY,
+    category TEXT NOT NULL,
+    code     TEXT NOT NULL,
+    name     TEXT NOT NULL,
+    type     TEXT NOT NULL CHECK (type IN ('string', 'number', 'enum', 'bool')),
+    required BOOLEAN NOT NULL DEFAULT false,
+    unit     TEXT NOT NULL DEFAULT '',
+    options  JSONB DEFAULT '[]',
+    UNIQUE (category, code)
+);
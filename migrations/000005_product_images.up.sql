@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS product_images (
+    id         BIGSERIAL PRIMARY KEY,
+    product_id BIGINT NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    url        TEXT NOT NULL,
+    alt_text   TEXT NOT NULL DEFAULT '',
+    position   INT NOT NULL,
+    is_primary BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_images_product_id_position ON product_images (product_id, position);
+-- At most one primary image per product.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_images_primary ON product_images (product_id) WHERE is_primary;
@@ -0,0 +1,37 @@
+CREATE TABLE categories (
+    id         BIGSERIAL PRIMARY KEY,
+    parent_id  BIGINT REFERENCES categories (id) ON DELETE RESTRICT,
+    name       TEXT NOT NULL,
+    slug       TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_categories_parent_id ON categories (parent_id);
+
+-- Map the free-text categories onto top level categories. The slug rule
+-- matches slugify in app/usecase/category.go.
+CREATE FUNCTION pg_temp.category_slug(category TEXT) RETURNS TEXT AS $$
+    SELECT COALESCE(NULLIF(TRIM(BOTH '-' FROM REGEXP_REPLACE(LOWER(TRIM(category)), '[^a-z0-9]+', '-', 'g')), ''), 'uncategorized')
+$$ LANGUAGE SQL IMMUTABLE;
+
+INSERT INTO categories (name, slug)
+SELECT DISTINCT ON (pg_temp.category_slug(category))
+       COALESCE(NULLIF(INITCAP(TRIM(category)), ''), 'Uncategorized'),
+       pg_temp.category_slug(category)
+FROM products
+ORDER BY pg_temp.category_slug(category), category;
+
+ALTER TABLE products ADD COLUMN category_id BIGINT REFERENCES categories (id);
+
+UPDATE products p
+SET category_id = c.id,
+    category    = c.slug
+FROM categories c
+WHERE c.slug = pg_temp.category_slug(p.category);
+
+ALTER TABLE products ALTER COLUMN category_id SET NOT NULL;
+
+CREATE INDEX idx_products_category_id ON products (category_id);
+
+UPDATE category_attributes SET category = pg_temp.category_slug(category);
@@ -0,0 +1,8 @@
+-- Name matches outrank description matches. The configuration must match
+-- searchConfig in app/repository/db/product_read.go.
+ALTER TABLE products ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+    SETWEIGHT(TO_TSVECTOR('simple', COALESCE(name, '')), 'A') ||
+    SETWEIGHT(TO_TSVECTOR('simple', COALESCE(description, '')), 'B')
+) STORED;
+
+CREATE INDEX idx_products_search_vector ON products USING GIN (search_vector);
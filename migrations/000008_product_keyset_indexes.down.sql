@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_products_active_price_id;
+DROP INDEX IF EXISTS idx_products_active_created_at_id;
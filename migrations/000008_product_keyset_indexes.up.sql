@@ -0,0 +1,3 @@
+-- Keyset pagination walks the active products by (sort key, id).
+CREATE INDEX idx_products_active_created_at_id ON products (created_at, id) WHERE active = true;
+CREATE INDEX idx_products_active_price_id ON products (price, id) WHERE active = true;
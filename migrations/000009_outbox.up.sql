@@ -0,0 +1,15 @@
+-- Side effects recorded in the same transaction as the change that caused
+-- them and delivered by the outbox relay, see app/usecase/outbox.go.
+CREATE TABLE outbox (
+    id              BIGSERIAL PRIMARY KEY,
+    topic           TEXT NOT NULL,
+    payload         JSONB NOT NULL,
+    attempts        INT NOT NULL DEFAULT 0,
+    last_error      TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    delivered_at    TIMESTAMPTZ,
+    dead_at         TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_outbox_pending ON outbox (next_attempt_at, id) WHERE delivered_at IS NULL AND dead_at IS NULL;
@@ -11,6 +11,8 @@ const (
 	RequestIDKey ctxKey = "request_id"
 	UserIDKey    ctxKey = "user_id"
 	ShopIDKey    ctxKey = "shop_id"
+	// PrimaryReadKey marks reads that must not be served by a read replica.
+	PrimaryReadKey ctxKey = "primary_read"
 )
 
 func WithRequestID(ctx context.Context, reqID string) context.Context {
@@ -26,6 +28,17 @@ func GetRequestID(ctx context.Context) string {
 	return ""
 }
 
+// WithPrimaryRead asks the repositories to read from the primary database,
+// e.g. because the data was just written by another instance.
+func WithPrimaryRead(ctx context.Context) context.Context {
+	return context.WithValue(ctx, PrimaryReadKey, true)
+}
+
+func IsPrimaryRead(ctx context.Context) bool {
+	primary, _ := ctx.Value(PrimaryReadKey).(bool)
+	return primary
+}
+
 func GetUserIDCtx(ctx context.Context) (int64, error) {
 	if v := ctx.Value(UserIDKey); v != nil {
 		if id, ok := v.(int64); ok {
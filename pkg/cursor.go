@@ -0,0 +1,24 @@
+package pkg
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+// EncodeCursor turns a pagination position into an opaque, URL safe token.
+func EncodeCursor(v any) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecodeCursor reverses EncodeCursor.
+func DecodeCursor(token string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,58 @@
+package pkg
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testCursor struct {
+	CreatedAt *time.Time `json:"c,omitempty"`
+	Price     *int64     `json:"p,omitempty"`
+	ID        int64      `json:"id"`
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	createdAt := time.Date(2025, 3, 1, 10, 30, 0, 123456000, time.UTC)
+	price := int64(9007199254740993)
+	want := testCursor{CreatedAt: &createdAt, Price: &price, ID: 42}
+
+	token, err := EncodeCursor(want)
+	if err != nil {
+		t.Fatalf("EncodeCursor: %v", err)
+	}
+	if strings.ContainsAny(token, "+/=") {
+		t.Fatalf("token %q is not URL safe", token)
+	}
+
+	var got testCursor
+	if err := DecodeCursor(token, &got); err != nil {
+		t.Fatalf("DecodeCursor: %v", err)
+	}
+	if got.ID != want.ID || *got.Price != *want.Price || !got.CreatedAt.Equal(*want.CreatedAt) {
+		t.Fatalf("decoded %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeCursorRejectsTamperedTokens(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "not base64", token: "not a cursor!"},
+		{name: "padded base64", token: base64.URLEncoding.EncodeToString([]byte(`{"id":1}`)) + "="},
+		{name: "not json", token: base64.RawURLEncoding.EncodeToString([]byte("id=1"))},
+		{name: "truncated json", token: base64.RawURLEncoding.EncodeToString([]byte(`{"id":1`))},
+		{name: "wrong type", token: base64.RawURLEncoding.EncodeToString([]byte(`{"id":"1"}`))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cursor testCursor
+			if err := DecodeCursor(tt.token, &cursor); err == nil {
+				t.Fatalf("want error, decoded %+v", cursor)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"log/slog"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	consumerPendingDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "consumer", "pending_messages"),
+		"Messages of the stream not yet delivered to the consumer.",
+		[]string{"consumer"}, nil)
+	consumerAckPendingDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "consumer", "ack_pending_messages"),
+		"Messages delivered to the consumer and not yet acknowledged.",
+		[]string{"consumer"}, nil)
+)
+
+// ConsumerInfo reports the pending counts of a consumer.
+type ConsumerInfo func() (pending, ackPending uint64, err error)
+
+type consumerCollector struct {
+	name string
+	info ConsumerInfo
+}
+
+// RegisterConsumer exports the pending counts of the named consumer, asking
+// info on every scrape.
+func RegisterConsumer(name string, info ConsumerInfo) {
+	prometheus.MustRegister(&consumerCollector{name: name, info: info})
+}
+
+func (c *consumerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- consumerPendingDesc
+	ch <- consumerAckPendingDesc
+}
+
+func (c *consumerCollector) Collect(ch chan<- prometheus.Metric) {
+	pending, ackPending, err := c.info()
+	if err != nil {
+		slog.Warn("[metrics] consumer info", "consumer", c.name, "error", err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(consumerPendingDesc, prometheus.GaugeValue, float64(pending), c.name)
+	ch <- prometheus.MustNewConstMetric(consumerAckPendingDesc, prometheus.GaugeValue, float64(ackPending), c.name)
+}
+
+var (
+	dbPoolConnsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "db_pool", "connections"),
+		"Connections of the pool by state (acquired, idle, constructing).",
+		[]string{"pool", "state"}, nil)
+	dbPoolMaxConnsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "db_pool", "max_connections"),
+		"Maximum size of the pool.",
+		[]string{"pool"}, nil)
+	dbPoolAcquiresDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "db_pool", "acquires_total"),
+		"Successful connection acquires.",
+		[]string{"pool"}, nil)
+	dbPoolEmptyAcquiresDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "db_pool", "empty_acquires_total"),
+		"Acquires that had to wait for a connection.",
+		[]string{"pool"}, nil)
+	dbPoolCanceledAcquiresDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "db_pool", "canceled_acquires_total"),
+		"Acquires canceled by their context.",
+		[]string{"pool"}, nil)
+	dbPoolAcquireSecondsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "db_pool", "acquire_seconds_total"),
+		"Total time spent acquiring connections.",
+		[]string{"pool"}, nil)
+)
+
+type dbPoolCollector struct {
+	name string
+	pool *pgxpool.Pool
+}
+
+// RegisterDBPool exports the statistics of the named pool.
+func RegisterDBPool(name string, pool *pgxpool.Pool) {
+	prometheus.MustRegister(&dbPoolCollector{name: name, pool: pool})
+}
+
+func (c *dbPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- dbPoolConnsDesc
+	ch <- dbPoolMaxConnsDesc
+	ch <- dbPoolAcquiresDesc
+	ch <- dbPoolEmptyAcquiresDesc
+	ch <- dbPoolCanceledAcquiresDesc
+	ch <- dbPoolAcquireSecondsDesc
+}
+
+func (c *dbPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(dbPoolConnsDesc, prometheus.GaugeValue, float64(stat.AcquiredConns()), c.name, "acquired")
+	ch <- prometheus.MustNewConstMetric(dbPoolConnsDesc, prometheus.GaugeValue, float64(stat.IdleConns()), c.name, "idle")
+	ch <- prometheus.MustNewConstMetric(dbPoolConnsDesc, prometheus.GaugeValue, float64(stat.ConstructingConns()), c.name, "constructing")
+	ch <- prometheus.MustNewConstMetric(dbPoolMaxConnsDesc, prometheus.GaugeValue, float64(stat.MaxConns()), c.name)
+	ch <- prometheus.MustNewConstMetric(dbPoolAcquiresDesc, prometheus.CounterValue, float64(stat.AcquireCount()), c.name)
+	ch <- prometheus.MustNewConstMetric(dbPoolEmptyAcquiresDesc, prometheus.CounterValue, float64(stat.EmptyAcquireCount()), c.name)
+	ch <- prometheus.MustNewConstMetric(dbPoolCanceledAcquiresDesc, prometheus.CounterValue, float64(stat.CanceledAcquireCount()), c.name)
+	ch <- prometheus.MustNewConstMetric(dbPoolAcquireSecondsDesc, prometheus.CounterValue, stat.AcquireDuration().Seconds(), c.name)
+}
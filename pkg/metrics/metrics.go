@@ -0,0 +1,113 @@
+// Package metrics defines the Prometheus metrics of the service. They are
+// registered with the default registry, which Handler serves.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "product_service"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	httpRequestsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "HTTP requests currently being served.",
+	})
+
+	stockCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "stock_cache_lookups_total",
+		Help:      "Stock cache lookups by kind (product, variant) and result (hit, miss, error).",
+	}, []string{"kind", "result"})
+
+	warehouseRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "warehouse_request_duration_seconds",
+		Help:      "Latency of warehouse service calls by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	warehouseRequestErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "warehouse_request_errors_total",
+		Help:      "Failed warehouse service calls by method, route and reason (transport, status, circuit_open).",
+	}, []string{"method", "route", "reason"})
+
+	consumerProcessingDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "consumer_processing_duration_seconds",
+		Help:      "Time spent handling a JetStream message by subject.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"subject"})
+
+	consumerMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "consumer_messages_total",
+		Help:      "Handled JetStream messages by subject and outcome (ack, nak, term).",
+	}, []string{"subject", "outcome"})
+)
+
+// Handler serves the registered metrics.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// HTTPRequestStarted tracks a request in flight and returns the function that
+// records it once served.
+func HTTPRequestStarted() func(method, route string, status int) {
+	start := time.Now()
+	httpRequestsInFlight.Inc()
+	return func(method, route string, status int) {
+		httpRequestsInFlight.Dec()
+		code := strconv.Itoa(status)
+		httpRequests.WithLabelValues(method, route, code).Inc()
+		httpRequestDuration.WithLabelValues(method, route, code).Observe(time.Since(start).Seconds())
+	}
+}
+
+// StockCacheLookup counts one cache lookup, result is hit, miss or error.
+func StockCacheLookup(kind, result string) {
+	stockCacheLookups.WithLabelValues(kind, result).Inc()
+}
+
+// StockCacheLookups counts a batch of lookups of the same outcome.
+func StockCacheLookups(kind, result string, count int) {
+	stockCacheLookups.WithLabelValues(kind, result).Add(float64(count))
+}
+
+func WarehouseRequest(method, route string, status int, duration time.Duration) {
+	warehouseRequestDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+func WarehouseError(method, route, reason string) {
+	warehouseRequestErrors.WithLabelValues(method, route, reason).Inc()
+}
+
+func ConsumerProcessed(subject string, duration time.Duration) {
+	consumerProcessingDuration.WithLabelValues(subject).Observe(duration.Seconds())
+}
+
+// ConsumerOutcome counts how a message was settled: ack, nak or term.
+func ConsumerOutcome(subject, outcome string) {
+	consumerMessages.WithLabelValues(subject, outcome).Inc()
+}